@@ -0,0 +1,31 @@
+// cmd/worker/main.go
+package main
+
+import (
+	"delayed-notifier/internal/app"
+	"delayed-notifier/internal/config"
+	"os"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+func main() {
+	zlog.Init()
+
+	cfg, err := config.MustLoad()
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("Failed to load config")
+	}
+
+	application, err := app.NewWorkerApp(cfg)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("Failed to create worker")
+	}
+
+	if err := application.Run(); err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("Worker failed")
+	}
+
+	zlog.Logger.Info().Msg("Worker exited successfully")
+	os.Exit(0)
+}
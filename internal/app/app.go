@@ -3,9 +3,7 @@ package app
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,60 +11,61 @@ import (
 	"syscall"
 	"time"
 
-	"delayed-notifier/internal/broker"
-	"delayed-notifier/internal/broker/rabbitmq"
 	"delayed-notifier/internal/config"
-	"delayed-notifier/internal/domain"
 	"delayed-notifier/internal/handler"
-	"delayed-notifier/internal/repository/delayed_repository/cache"
-	"delayed-notifier/internal/repository/delayed_repository/cache/redis"
+	"delayed-notifier/internal/outbox"
 	"delayed-notifier/internal/repository/delayed_repository/repo/postgres"
-	delayed_uc "delayed-notifier/internal/usecase/delayed_usecase"
-	"delayed-notifier/internal/usecase/notifier"
+	"delayed-notifier/internal/scheduler"
+	"delayed-notifier/internal/worker"
 
-	"github.com/rabbitmq/amqp091-go"
-	"github.com/wb-go/wbf/dbpg"
 	"github.com/wb-go/wbf/zlog"
 )
 
+// component is a long-running part of the process. A critical component that
+// stops with an error shuts the whole process down.
+type component struct {
+	name     string
+	run      func(ctx context.Context) error
+	critical bool
+}
+
+// App runs a set of components on top of the shared dependencies. The API and
+// the worker processes differ only in the components they run.
 type App struct {
-	cfg    *config.Config
-	db     *dbpg.DB
-	cache  cache.Cache
-	broker broker.Broker
-	uc     handler.NotificationService
-	server *http.Server
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	name       string
+	cfg        *config.Config
+	deps       *deps
+	components []component
+	servers    []*http.Server
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
 }
 
+// NewApp builds the API process: HTTP server, outbox relay, recovery scheduler
+// and, unless APP_MODE=api, the delivery consumer.
 func NewApp(cfg *config.Config) (*App, error) {
-	retries := cfg.DefaultRetryStrategy()
-
-	dbOpts := &dbpg.Options{
-		MaxOpenConns:    cfg.DB.MaxOpenConns,
-		MaxIdleConns:    cfg.DB.MaxIdleConns,
-		ConnMaxLifetime: cfg.DB.ConnMaxLifetime,
-	}
-	db, err := dbpg.New(cfg.DBDSN(), cfg.DB.Slaves, dbOpts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
-	}
-
-	cache := redis.NewRedisCache(cfg, retries)
-	repo := postgres.NewNotificationRepository(db, cache, retries, time.Duration(cfg.CacheTTLHours)*time.Hour)
-
-	broker, err := rabbitmq.NewRabbitMQ(cfg, retries)
+	d, err := newDeps(cfg)
 	if err != nil {
-		db.Master.Close()
-		cache.Close()
-		return nil, fmt.Errorf("failed to create RabbitMQ broker: %w", err)
+		return nil, err
 	}
 
-	notifier := notifier.NewMultiNotifier(cfg)
-	uc := delayed_uc.NewNotificationUsecase(repo, broker, retries, notifier)
-
-	h := handler.NewHandler(uc)
+	sched := scheduler.NewScheduler(d.repo, d.broker, scheduler.Config{
+		Interval:     cfg.Scheduler.Interval,
+		BatchSize:    cfg.Scheduler.BatchSize,
+		GracePeriod:  cfg.Scheduler.GracePeriod,
+		LeaseTimeout: cfg.Scheduler.LeaseTimeout,
+	})
+	outboxRepo := postgres.NewOutboxRepository(d.db, d.retries, postgres.OutboxBackoff{
+		MaxAttempts: cfg.Outbox.MaxAttempts,
+		Delay:       cfg.Outbox.RetryDelay,
+		MaxDelay:    cfg.Outbox.MaxRetryDelay,
+	})
+	relay := outbox.NewRelay(outboxRepo, d.broker, outbox.Config{
+		PollInterval: cfg.Outbox.PollInterval,
+		BatchSize:    cfg.Outbox.BatchSize,
+	})
+
+	h := handler.NewHandler(d.uc, d.templates, d.channels, d.recipients, d.preferences)
 	mux := handler.SetupRouter(h)
 	muxWithMw := handler.LoggingMiddleware(mux)
 
@@ -76,72 +75,108 @@ func NewApp(cfg *config.Config) (*App, error) {
 	}
 
 	app := &App{
-		cfg:    cfg,
-		db:     db,
-		cache:  cache,
-		broker: broker,
-		uc:     uc,
-		server: server,
+		name: "application",
+		cfg:  cfg,
+		deps: d,
 	}
+	if cfg.RunsConsumer() {
+		app.components = append(app.components, consumer(d, false), app.metrics())
+	} else {
+		zlog.Logger.Info().Msg("Running in API-only mode, delivery is handled by cmd/worker")
+	}
+	app.components = append(app.components,
+		component{name: "Outbox relay", run: relay.Run},
+		component{name: "Scheduler", run: sched.Run},
+		app.serve("HTTP server", server, true),
+	)
+	if d.dropLegacyCache != nil {
+		app.components = append(app.components, component{name: "Legacy cache cleanup", run: d.dropLegacyCache})
+	}
+	return app, nil
+}
 
+// NewWorkerApp builds the standalone delivery process that only consumes the
+// notifications queue.
+func NewWorkerApp(cfg *config.Config) (*App, error) {
+	d, err := newDeps(cfg)
+	if err != nil {
+		return nil, err
+	}
+	app := &App{
+		name: "worker",
+		cfg:  cfg,
+		deps: d,
+	}
+	app.components = []component{consumer(d, true), app.metrics()}
 	return app, nil
 }
 
-func (a *App) Run() error {
-	ctx, cancel := context.WithCancel(context.Background())
-	a.cancel = cancel
+func consumer(d *deps, critical bool) component {
+	return component{
+		name:     "Consumer",
+		run:      worker.NewWorker(d.broker, d.uc).Run,
+		critical: critical,
+	}
+}
 
-	zlog.Logger.Info().Msg("Starting application...")
+// metrics serves expvar on the internal METRICS_ADDR listener; the counters
+// live in the process that sends notifications.
+func (a *App) metrics() component {
+	server := &http.Server{
+		Addr:    a.cfg.Server.MetricsAddr,
+		Handler: handler.SetupMetricsRouter(),
+	}
+	return a.serve("Metrics server", server, false)
+}
 
-	handler := func(ctx context.Context, msg amqp091.Delivery) error {
-		var payload struct {
-			ID string `json:"id"`
-		}
-		if err := json.Unmarshal(msg.Body, &payload); err != nil {
-			zlog.Logger.Error().Err(err).Msg("Failed to unmarshal message")
-			return err
-		}
-		if payload.ID == "" {
-			zlog.Logger.Error().Msg("Missing ID in payload")
-			return domain.ErrNotFound
-		}
-		err := a.uc.ProcessNotification(ctx, payload.ID)
-		if err != nil {
-			zlog.Logger.Error().Err(err).Str("id", payload.ID).Msg("Failed to process notification")
-			return err
-		}
-		zlog.Logger.Info().Str("id", payload.ID).Msg("Notification processed successfully")
-		return nil
+func (a *App) serve(name string, server *http.Server, critical bool) component {
+	a.servers = append(a.servers, server)
+	return component{
+		name:     name,
+		critical: critical,
+		run: func(ctx context.Context) error {
+			zlog.Logger.Info().Str("addr", server.Addr).Msgf("Starting %s", name)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
 	}
+}
 
-	a.wg.Add(1)
-	go func() {
-		defer a.wg.Done()
-		if err := a.broker.Consume(ctx, "notifications", handler); err != nil && !errors.Is(err, context.Canceled) {
-			zlog.Logger.Error().Err(err).Msg("Consumer stopped with error")
-		}
-	}()
+func (a *App) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
 
-	a.wg.Add(1)
-	go func() {
-		defer a.wg.Done()
-		zlog.Logger.Info().Str("addr", a.server.Addr).Msg("Starting HTTP server")
-		if err := a.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			zlog.Logger.Error().Err(err).Msg("HTTP server failed")
-			cancel()
-		}
-	}()
+	zlog.Logger.Info().Msgf("Starting %s...", a.name)
+
+	for _, c := range a.components {
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			if err := c.run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				zlog.Logger.Error().Err(err).Msgf("%s stopped with error", c.name)
+				if c.critical {
+					cancel()
+				}
+			}
+		}()
+	}
 
-	a.waitForShutdown()
+	a.waitForShutdown(ctx)
 	return nil
 }
 
-func (a *App) waitForShutdown() {
+func (a *App) waitForShutdown(ctx context.Context) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
-	<-quit
-	zlog.Logger.Info().Msg("Received shutdown signal")
+	select {
+	case <-quit:
+		zlog.Logger.Info().Msg("Received shutdown signal")
+	case <-ctx.Done():
+		zlog.Logger.Warn().Msgf("A critical component failed, stopping %s", a.name)
+	}
 
 	a.Shutdown()
 }
@@ -155,24 +190,13 @@ func (a *App) Shutdown() {
 
 	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), a.cfg.Server.ShutdownTimeout)
 	defer cancelShutdown()
-
-	if err := a.server.Shutdown(ctxShutdown); err != nil {
-		zlog.Logger.Error().Err(err).Msg("Failed to shutdown HTTP server gracefully")
-	}
-
-	if a.broker != nil {
-		if err := a.broker.Close(); err != nil {
-			zlog.Logger.Error().Err(err).Msg("Failed to close broker connection")
+	for _, server := range a.servers {
+		if err := server.Shutdown(ctxShutdown); err != nil {
+			zlog.Logger.Error().Err(err).Str("addr", server.Addr).Msg("Failed to shutdown HTTP server gracefully")
 		}
 	}
 
-	if a.db != nil {
-		a.db.Master.Close()
-	}
-
-	if a.cache != nil {
-		a.cache.Close()
-	}
+	a.deps.close()
 
 	done := make(chan struct{})
 	go func() {
@@ -187,5 +211,5 @@ func (a *App) Shutdown() {
 		zlog.Logger.Warn().Msg("Shutdown timeout exceeded, forcing exit")
 	}
 
-	zlog.Logger.Info().Msg("Application stopped")
+	zlog.Logger.Info().Msgf("Stopped %s", a.name)
 }
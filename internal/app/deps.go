@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"delayed-notifier/internal/broker"
+	"delayed-notifier/internal/broker/rabbitmq"
+	"delayed-notifier/internal/config"
+	"delayed-notifier/internal/repository/delayed_repository/cache"
+	"delayed-notifier/internal/repository/delayed_repository/cache/redis"
+	"delayed-notifier/internal/repository/delayed_repository/repo/postgres"
+	delayed_uc "delayed-notifier/internal/usecase/delayed_usecase"
+	"delayed-notifier/internal/usecase/notifier"
+	preference_uc "delayed-notifier/internal/usecase/preference_usecase"
+	recipient_uc "delayed-notifier/internal/usecase/recipient_usecase"
+	template_uc "delayed-notifier/internal/usecase/template_usecase"
+
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+)
+
+// deps holds the connections and use cases shared by the API and the
+// delivery worker processes.
+type deps struct {
+	retries     retry.Strategy
+	db          *dbpg.DB
+	cache       cache.Cache
+	broker      broker.Broker
+	channels    *notifier.Registry
+	repo        *postgres.NotificationRepository
+	templates   *template_uc.TemplateUsecase
+	recipients  *recipient_uc.RecipientUsecase
+	preferences *preference_uc.PreferenceUsecase
+	uc          *delayed_uc.NotificationUsecase
+	// dropLegacyCache is set when the Redis cache is enabled.
+	dropLegacyCache func(ctx context.Context) error
+}
+
+func newDeps(cfg *config.Config) (*deps, error) {
+	d := &deps{retries: cfg.DefaultRetryStrategy()}
+
+	dbOpts := &dbpg.Options{
+		MaxOpenConns:    cfg.DB.MaxOpenConns,
+		MaxIdleConns:    cfg.DB.MaxIdleConns,
+		ConnMaxLifetime: cfg.DB.ConnMaxLifetime,
+	}
+	db, err := dbpg.New(cfg.DBDSN(), cfg.DB.Slaves, dbOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	d.db = db
+
+	d.cache = cache.NewNoopCache()
+	if cfg.Cache.Enabled {
+		redisCache := redis.NewRedisCache(cfg, d.retries)
+		d.cache = redisCache
+		d.dropLegacyCache = redisCache.DropLegacyKeys
+	}
+	d.repo = postgres.NewNotificationRepository(db, d.cache, d.retries)
+
+	d.broker, err = rabbitmq.NewRabbitMQ(cfg, d.retries)
+	if err != nil {
+		d.close()
+		return nil, fmt.Errorf("failed to create RabbitMQ broker: %w", err)
+	}
+
+	d.channels, err = notifier.NewRegistry(cfg, notifier.Builtin()...)
+	if err != nil {
+		d.close()
+		return nil, fmt.Errorf("failed to load notification channels: %w", err)
+	}
+	d.templates = template_uc.NewTemplateUsecase(postgres.NewTemplateRepository(db, d.retries))
+	d.recipients = recipient_uc.NewRecipientUsecase(postgres.NewRecipientRepository(db, d.retries), d.channels)
+	d.preferences = preference_uc.NewPreferenceUsecase(postgres.NewPreferenceRepository(db, d.retries))
+	d.uc = delayed_uc.NewNotificationUsecase(
+		d.repo, d.broker, d.retries, d.channels, d.templates, d.recipients, d.preferences,
+	)
+	return d, nil
+}
+
+// close releases whatever newDeps managed to open.
+func (d *deps) close() {
+	if d.broker != nil {
+		if err := d.broker.Close(); err != nil {
+			zlog.Logger.Error().Err(err).Msg("Failed to close broker connection")
+		}
+	}
+
+	if d.db != nil {
+		d.db.Master.Close()
+	}
+
+	if d.cache != nil {
+		d.cache.Close()
+	}
+
+	if d.channels != nil {
+		if err := d.channels.Close(); err != nil {
+			zlog.Logger.Error().Err(err).Msg("Failed to close notification channels")
+		}
+	}
+}
@@ -7,9 +7,18 @@ import (
 	wbfrabbit "github.com/wb-go/wbf/rabbitmq"
 )
 
+// DelayedMessage asks for the notification to be delivered after Delay.
+type DelayedMessage struct {
+	NotificationID string
+	Version        int
+	Delay          time.Duration
+}
+
 type Broker interface {
 	Publish(ctx context.Context, exchange, key string, body []byte) error
-	PublishDelayed(ctx context.Context, notificationID string, delay time.Duration) error
+	PublishDelayed(ctx context.Context, notificationID string, version int, delay time.Duration) error
+	// PublishDelayedBatch returns the error of each message in msgs order.
+	PublishDelayedBatch(ctx context.Context, msgs []DelayedMessage) []error
 	Consume(ctx context.Context, queue string, handler wbfrabbit.MessageHandler) error
 	Close() error
 }
@@ -2,6 +2,7 @@ package rabbitmq
 
 import (
 	"context"
+	"delayed-notifier/internal/broker"
 	"delayed-notifier/internal/config"
 	"errors"
 	"time"
@@ -15,15 +16,23 @@ import (
 type RabbitMQ struct {
 	client    *wbfrabbit.RabbitClient
 	retries   retry.Strategy
+	workers   int
+	prefetch  int
 	consumer  *Consumer
 	publisher *Publisher
 }
 
 func NewRabbitMQ(cfg *config.Config, retries retry.Strategy) (*RabbitMQ, error) {
+	// wbf skips the call entirely when a strategy has zero attempts, so every
+	// strategy has to be set explicitly. Message handling is attempted once:
+	// redelivery is driven by ProcessNotification and the Nack/DLQ settings.
 	rabbitCfg := wbfrabbit.ClientConfig{
 		URL:            cfg.RabbitMQDSN(),
 		ConnectTimeout: cfg.RabbitMQ.ConnectTimeout,
 		Heartbeat:      cfg.RabbitMQ.Heartbeat,
+		ReconnectStrat: retries,
+		ProducingStrat: retries,
+		ConsumingStrat: retry.Strategy{Attempts: 1},
 	}
 	client, err := wbfrabbit.NewClient(rabbitCfg)
 	if err != nil {
@@ -53,12 +62,18 @@ func NewRabbitMQ(cfg *config.Config, retries retry.Strategy) (*RabbitMQ, error)
 	if err != nil {
 		zlog.Logger.Fatal().Err(err).Msg("Failed to declare DLQ")
 	}
-	return &RabbitMQ{client: client, retries: retries}, nil
+	return &RabbitMQ{
+		client:    client,
+		retries:   retries,
+		publisher: NewPublisher(client, retries),
+		workers:   cfg.Worker.Concurrency,
+		prefetch:  cfg.Worker.PrefetchCount,
+	}, nil
 }
 
 func (b *RabbitMQ) Publish(ctx context.Context, exchange, key string, body []byte) error {
 	if b.publisher == nil {
-		b.publisher = NewPublisher(b.client)
+		b.publisher = NewPublisher(b.client, b.retries)
 	}
 	if exchange != "delayed_notifications" {
 		return errors.New("unsupported exchange")
@@ -66,11 +81,18 @@ func (b *RabbitMQ) Publish(ctx context.Context, exchange, key string, body []byt
 	return b.publisher.publisher.Publish(ctx, body, key)
 }
 
-func (b *RabbitMQ) PublishDelayed(ctx context.Context, id string, delay time.Duration) error {
+func (b *RabbitMQ) PublishDelayed(ctx context.Context, id string, version int, delay time.Duration) error {
 	if b.publisher == nil {
-		b.publisher = NewPublisher(b.client)
+		b.publisher = NewPublisher(b.client, b.retries)
 	}
-	return b.publisher.PublishDelayed(ctx, id, delay)
+	return b.publisher.PublishDelayed(ctx, id, version, delay)
+}
+
+func (b *RabbitMQ) PublishDelayedBatch(ctx context.Context, msgs []broker.DelayedMessage) []error {
+	if b.publisher == nil {
+		b.publisher = NewPublisher(b.client, b.retries)
+	}
+	return b.publisher.PublishDelayedBatch(ctx, msgs)
 }
 
 func (b *RabbitMQ) Consume(ctx context.Context, queue string, handler wbfrabbit.MessageHandler) error {
@@ -78,8 +100,8 @@ func (b *RabbitMQ) Consume(ctx context.Context, queue string, handler wbfrabbit.
 		Queue:         queue,
 		ConsumerTag:   "notification_consumer",
 		AutoAck:       false,
-		Workers:       5,
-		PrefetchCount: 10,
+		Workers:       b.workers,
+		PrefetchCount: b.prefetch,
 		Nack:          wbfrabbit.NackConfig{Multiple: false, Requeue: true},
 		Ask:           wbfrabbit.AskConfig{Multiple: false},
 	}
@@ -3,39 +3,169 @@ package rabbitmq
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
+	"delayed-notifier/internal/broker"
+
 	amqp "github.com/rabbitmq/amqp091-go"
 	wbfrabbit "github.com/wb-go/wbf/rabbitmq"
+	"github.com/wb-go/wbf/retry"
 	"github.com/wb-go/wbf/zlog"
 )
 
+const routingKey = "notify"
+
+var errPublishNacked = errors.New("message was not confirmed by broker")
+
 type Publisher struct {
+	client    *wbfrabbit.RabbitClient
 	publisher *wbfrabbit.Publisher
+	retries   retry.Strategy
 }
 
-func NewPublisher(client *wbfrabbit.RabbitClient) *Publisher {
+func NewPublisher(client *wbfrabbit.RabbitClient, retries retry.Strategy) *Publisher {
 	return &Publisher{
+		client:    client,
 		publisher: wbfrabbit.NewPublisher(client, "delayed_notifications", "application/json"),
+		retries:   retries,
 	}
 }
 
-func (p *Publisher) PublishDelayed(ctx context.Context, id string, delay time.Duration) error {
+func (p *Publisher) PublishDelayed(ctx context.Context, id string, version int, delay time.Duration) error {
+	msg, err := delayedPublishing(broker.DelayedMessage{NotificationID: id, Version: version, Delay: delay})
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("Failed to marshal payload")
+		return err
+	}
+
+	zlog.Logger.Info().Str("id", id).Int("version", version).Int64("delay_ms", delay.Milliseconds()).Msg("Publishing delayed message")
+
+	return retry.DoContext(ctx, p.retries, func() error {
+		return p.publishConfirmed(ctx, msg)
+	})
+}
+
+// PublishDelayedBatch publishes the messages on one confirm-mode channel and
+// only then waits for their confirms, so the batch costs one round trip
+// instead of one per message. The result holds the error of each message;
+// the unconfirmed ones are retried together.
+func (p *Publisher) PublishDelayedBatch(ctx context.Context, msgs []broker.DelayedMessage) []error {
+	errs := make([]error, len(msgs))
+	publishings := make([]amqp.Publishing, len(msgs))
+	var pending []int
+	for i, msg := range msgs {
+		publishings[i], errs[i] = delayedPublishing(msg)
+		if errs[i] == nil {
+			pending = append(pending, i)
+		}
+	}
+
+	zlog.Logger.Info().Int("count", len(pending)).Msg("Publishing delayed messages")
+
+	retry.DoContext(ctx, p.retries, func() error {
+		pending = p.publishBatchConfirmed(ctx, publishings, pending, errs)
+		if len(pending) > 0 {
+			return errs[pending[0]]
+		}
+		return nil
+	})
+	return errs
+}
+
+func delayedPublishing(msg broker.DelayedMessage) (amqp.Publishing, error) {
 	payload := struct {
-		ID string `json:"id"`
+		ID      string `json:"id"`
+		Version int    `json:"version,omitempty"`
 	}{
-		ID: id,
+		ID:      msg.NotificationID,
+		Version: msg.Version,
 	}
 	body, err := json.Marshal(payload)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Msg("Failed to marshal payload")
+		return amqp.Publishing{}, err
+	}
+	return amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Headers:      amqp.Table{"x-delay": int(msg.Delay.Milliseconds())},
+		Body:         body,
+	}, nil
+}
+
+// publishConfirmed publishes a persistent message on a confirm-mode channel and
+// waits for the broker ack, so a nil error means RabbitMQ has taken ownership.
+func (p *Publisher) publishConfirmed(ctx context.Context, msg amqp.Publishing) error {
+	ch, err := p.confirmChannel()
+	if err != nil {
 		return err
 	}
+	defer ch.Close()
 
-	delayMs := int(delay.Milliseconds())
-	headers := amqp.Table{"x-delay": delayMs}
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, p.publisher.GetExchangeName(), routingKey, false, false, msg)
+	if err != nil {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return waitConfirm(ctx, confirm)
+}
 
-	zlog.Logger.Info().Str("id", id).Int("delay_ms", delayMs).Msg("Publishing delayed message")
+// publishBatchConfirmed publishes msgs[i] for every i in pending, records the
+// outcome in errs and returns the indexes that were not confirmed.
+func (p *Publisher) publishBatchConfirmed(ctx context.Context, msgs []amqp.Publishing, pending []int, errs []error) []int {
+	ch, err := p.confirmChannel()
+	if err != nil {
+		for _, i := range pending {
+			errs[i] = err
+		}
+		return pending
+	}
+	defer ch.Close()
 
-	return p.publisher.Publish(ctx, body, "notify", wbfrabbit.WithHeaders(headers))
+	confirms := make([]*amqp.DeferredConfirmation, len(pending))
+	for j, i := range pending {
+		confirms[j], err = ch.PublishWithDeferredConfirmWithContext(ctx, p.publisher.GetExchangeName(), routingKey, false, false, msgs[i])
+		if err != nil {
+			// The channel is closed after a failed publish, so the rest of the
+			// batch waits for the next attempt.
+			for _, i := range pending[j:] {
+				errs[i] = fmt.Errorf("failed to publish message: %w", err)
+			}
+			break
+		}
+	}
+	var failed []int
+	for j, i := range pending {
+		if confirms[j] == nil {
+			failed = append(failed, i)
+			continue
+		}
+		if errs[i] = waitConfirm(ctx, confirms[j]); errs[i] != nil {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
+
+func (p *Publisher) confirmChannel() (*amqp.Channel, error) {
+	ch, err := p.client.GetChannel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+	return ch, nil
+}
+
+func waitConfirm(ctx context.Context, confirm *amqp.DeferredConfirmation) error {
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for publisher confirm: %w", err)
+	}
+	if !acked {
+		return errPublishNacked
+	}
+	return nil
 }
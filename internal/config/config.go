@@ -37,29 +37,77 @@ type Config struct {
 		ConnectTimeout time.Duration `env:"RABBITMQ_CONNECT_TIMEOUT"`
 		Heartbeat      time.Duration `env:"RABBITMQ_HEARTBEAT"`
 	}
+	Mode   string `env:"APP_MODE" env-default:"all" validate:"oneof=all api"`
 	Server struct {
 		Addr            string        `env:"SERVER_PORT" validate:"required"`
 		ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" env-default:"10s"`
+		// MetricsAddr is the internal listener for /debug/vars in processes
+		// that deliver notifications.
+		MetricsAddr string `env:"METRICS_ADDR" env-default:"127.0.0.1:9090" validate:"required"`
 	}
 	Retries struct {
 		Attempts int     `env:"RETRIES_ATTEMPTS" validate:"required"`
 		DelayMs  int     `env:"RETRIES_DELAY_MS" validate:"required"`
 		Backoff  float64 `env:"RETRIES_BACKOFF" validate:"required"`
 	}
-	CacheTTLHours int `env:"CACHE_TTL_HOURS" validate:"required,gte=1"`
-	Email         Email
-	Telegram      Telegram
+	Worker struct {
+		Concurrency   int `env:"WORKER_CONCURRENCY" env-default:"5" validate:"gte=1"`
+		PrefetchCount int `env:"WORKER_PREFETCH_COUNT" env-default:"10" validate:"gte=1"`
+	}
+	Scheduler struct {
+		Interval     time.Duration `env:"SCHEDULER_INTERVAL" env-default:"30s" validate:"gt=0"`
+		BatchSize    int           `env:"SCHEDULER_BATCH_SIZE" env-default:"100" validate:"gte=1"`
+		GracePeriod  time.Duration `env:"SCHEDULER_GRACE_PERIOD" env-default:"1m"`
+		LeaseTimeout time.Duration `env:"PROCESSING_LEASE_TIMEOUT" env-default:"5m" validate:"gt=0"`
+	}
+	Outbox struct {
+		PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" env-default:"1s" validate:"gt=0"`
+		BatchSize    int           `env:"OUTBOX_BATCH_SIZE" env-default:"100" validate:"gte=1"`
+		// Failed entries are retried with a delay doubling from RetryDelay up
+		// to MaxRetryDelay and dead-lettered after MaxAttempts.
+		MaxAttempts   int           `env:"OUTBOX_MAX_ATTEMPTS" env-default:"10" validate:"gte=1"`
+		RetryDelay    time.Duration `env:"OUTBOX_RETRY_DELAY" env-default:"1s" validate:"gt=0"`
+		MaxRetryDelay time.Duration `env:"OUTBOX_MAX_RETRY_DELAY" env-default:"5m" validate:"gt=0"`
+	}
+	Cache    Cache
+	Email    Email
+	Telegram Telegram
+	Webhook  Webhook
+}
+
+// Cache configures the Redis notification cache. With Enabled = false reads
+// go straight to PostgreSQL and Redis is not used.
+type Cache struct {
+	Enabled     bool          `env:"CACHE_ENABLED" env-default:"true"`
+	TTLHours    int           `env:"CACHE_TTL_HOURS" env-default:"24" validate:"gte=1"`
+	NegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL" env-default:"30s" validate:"gt=0"`
 }
 
 type Email struct {
-	SmtpHost string `env:"EMAIL_SMTP_HOST"`
-	SmtpPort int    `env:"EMAIL_SMTP_PORT"`
-	User     string `env:"EMAIL_USER"`
-	Pass     string `env:"EMAIL_PASSWORD"`
+	SmtpHost    string        `env:"EMAIL_SMTP_HOST"`
+	SmtpPort    int           `env:"EMAIL_SMTP_PORT"`
+	User        string        `env:"EMAIL_USER"`
+	Pass        string        `env:"EMAIL_PASSWORD"`
+	TLSMode     string        `env:"EMAIL_TLS_MODE" env-default:"starttls" validate:"oneof=none starttls tls"`
+	AuthMethod  string        `env:"EMAIL_AUTH_METHOD" env-default:"plain" validate:"oneof=plain login cram-md5"`
+	PoolSize    int           `env:"EMAIL_POOL_SIZE" env-default:"5" validate:"gte=0"`
+	IdleTimeout time.Duration `env:"EMAIL_IDLE_TIMEOUT" env-default:"1m"`
+	Timeout     time.Duration `env:"EMAIL_TIMEOUT" env-default:"30s" validate:"gt=0"`
 }
 
 type Telegram struct {
-	BotToken string `env:"TELEGRAM_BOT_TOKEN"`
+	BotToken          string        `env:"TELEGRAM_BOT_TOKEN"`
+	APIEndpoint       string        `env:"TELEGRAM_API_ENDPOINT"`
+	GlobalRate        float64       `env:"TELEGRAM_GLOBAL_RATE" env-default:"30" validate:"gt=0"`
+	ChatRate          float64       `env:"TELEGRAM_CHAT_RATE" env-default:"1" validate:"gt=0"`
+	MaxRetryAfterWait time.Duration `env:"TELEGRAM_MAX_RETRY_AFTER_WAIT" env-default:"5s"`
+	Limiter           string        `env:"TELEGRAM_LIMITER" env-default:"redis" validate:"oneof=redis local"`
+}
+
+type Webhook struct {
+	Secret               string        `env:"WEBHOOK_SECRET"`
+	Timeout              time.Duration `env:"WEBHOOK_TIMEOUT" env-default:"10s" validate:"gt=0"`
+	AllowPrivateNetworks bool          `env:"WEBHOOK_ALLOW_PRIVATE_NETWORKS" env-default:"false"`
 }
 
 func MustLoad() (*Config, error) {
@@ -83,6 +131,10 @@ func MustLoad() (*Config, error) {
 	return &cfg, nil
 }
 
+func (c *Config) RunsConsumer() bool {
+	return c.Mode != "api"
+}
+
 func (c *Config) DBDSN() string {
 	return fmt.Sprintf("postgres://%s:%s@%s:%d/%s?sslmode=disable",
 		c.DB.User, c.DB.Pass, c.DB.Host, c.DB.Port, c.DB.DBName)
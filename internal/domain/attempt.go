@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+type DeliveryAttempt struct {
+	ID             int64
+	NotificationID string
+	Channel        NotificationChannel
+	StartedAt      time.Time
+	Duration       time.Duration
+	Success        bool
+	Error          string
+	ProviderCode   string
+}
+
+// ProviderError carries the code returned by the delivery provider, such as an
+// SMTP reply code or a Telegram error code, so it can be stored with the
+// attempt.
+type ProviderError struct {
+	Code string
+	Err  error
+}
+
+func (e *ProviderError) Error() string { return e.Err.Error() }
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+func ProviderCode(err error) string {
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		return pe.Code
+	}
+	return ""
+}
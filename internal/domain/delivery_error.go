@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+type DeliveryErrorKind int
+
+const (
+	DeliveryTransient DeliveryErrorKind = iota
+	DeliveryPermanent
+	DeliveryRateLimited
+)
+
+// DeliveryError classifies a failed send. Errors that are not wrapped in a
+// DeliveryError are treated as transient.
+type DeliveryError struct {
+	Kind       DeliveryErrorKind
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *DeliveryError) Error() string { return e.Err.Error() }
+
+func (e *DeliveryError) Unwrap() error { return e.Err }
+
+func PermanentError(err error) error {
+	return &DeliveryError{Kind: DeliveryPermanent, Err: err}
+}
+
+func RateLimitedError(err error, retryAfter time.Duration) error {
+	return &DeliveryError{Kind: DeliveryRateLimited, RetryAfter: retryAfter, Err: err}
+}
+
+func DeliveryErrorKindOf(err error) DeliveryErrorKind {
+	var de *DeliveryError
+	if errors.As(err, &de) {
+		return de.Kind
+	}
+	return DeliveryTransient
+}
+
+// RetryAfter returns the delay requested by a rate-limited provider.
+func RetryAfter(err error) (time.Duration, bool) {
+	var de *DeliveryError
+	if errors.As(err, &de) && de.Kind == DeliveryRateLimited {
+		return de.RetryAfter, true
+	}
+	return 0, false
+}
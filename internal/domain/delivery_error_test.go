@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDeliveryErrorKindOf(t *testing.T) {
+	base := errors.New("boom")
+	tests := []struct {
+		name string
+		err  error
+		kind DeliveryErrorKind
+	}{
+		{name: "plain error is transient", err: base, kind: DeliveryTransient},
+		{name: "permanent", err: PermanentError(base), kind: DeliveryPermanent},
+		{name: "rate limited", err: RateLimitedError(base, time.Second), kind: DeliveryRateLimited},
+		{name: "wrapped permanent", err: fmt.Errorf("failed to send: %w", PermanentError(base)), kind: DeliveryPermanent},
+		{name: "provider error inside", err: PermanentError(&ProviderError{Code: "smtp 550", Err: base}), kind: DeliveryPermanent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DeliveryErrorKindOf(tt.err); got != tt.kind {
+				t.Fatalf("DeliveryErrorKindOf() = %v, want %v", got, tt.kind)
+			}
+			if !errors.Is(tt.err, base) {
+				t.Fatal("classification hides the original error")
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if got, ok := RetryAfter(fmt.Errorf("wrapped: %w", RateLimitedError(errors.New("429"), 3*time.Second))); !ok || got != 3*time.Second {
+		t.Fatalf("RetryAfter() = %v, %v, want 3s", got, ok)
+	}
+	if _, ok := RetryAfter(PermanentError(errors.New("400"))); ok {
+		t.Fatal("RetryAfter() reported a delay for a permanent error")
+	}
+}
+
+func TestProviderCode(t *testing.T) {
+	err := RateLimitedError(&ProviderError{Code: "telegram 429", Err: errors.New("too many requests")}, time.Second)
+	if got := ProviderCode(err); got != "telegram 429" {
+		t.Fatalf("ProviderCode() = %q", got)
+	}
+	if got := ProviderCode(errors.New("dial tcp: timeout")); got != "" {
+		t.Fatalf("ProviderCode() = %q, want empty", got)
+	}
+}
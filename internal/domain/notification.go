@@ -1,17 +1,23 @@
 package domain
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 )
 
 type NotificationStatus string
 
 const (
-	StatusPending   NotificationStatus = "pending"
-	StatusSent      NotificationStatus = "sent"
-	StatusCancelled NotificationStatus = "cancelled"
-	StatusFailed    NotificationStatus = "failed"
+	StatusPending    NotificationStatus = "pending"
+	StatusProcessing NotificationStatus = "processing"
+	StatusSent       NotificationStatus = "sent"
+	StatusCancelled  NotificationStatus = "cancelled"
+	StatusFailed     NotificationStatus = "failed"
+	// StatusSuppressed means the user's preferences ruled the notification
+	// out; SuppressionReason says which one.
+	StatusSuppressed NotificationStatus = "suppressed"
 )
 
 type NotificationChannel string
@@ -19,30 +25,223 @@ type NotificationChannel string
 const (
 	ChannelEmail    NotificationChannel = "email"
 	ChannelTelegram NotificationChannel = "telegram"
+	ChannelWebhook  NotificationChannel = "webhook"
 )
 
 type Notification struct {
-	ID        string
-	UserID    string
-	Channel   NotificationChannel
-	Message   string
-	SendAt    time.Time
-	Status    NotificationStatus
-	Retries   int
+	ID     string
+	UserID string
+	// Channel is the channel currently used for delivery. Channels holds the
+	// whole fallback chain in order; once Channel runs out of retries delivery
+	// moves on to the channel that follows it. When the notification is sent,
+	// Channel is the one that delivered it.
+	Channel        NotificationChannel
+	Channels       []NotificationChannel
+	Category       string
+	Subject        string
+	Message        string
+	SendAt         time.Time
+	Status         NotificationStatus
+	Retries        int
+	Version        int
+	IdempotencyKey string
+	RequestHash    string
+	SeriesID       string
+	TemplateID     string
+	TemplateParams map[string]any
+	// ClientID identifies the API client that created the notification;
+	// ExternalID is the client's own reference and is unique per client.
+	// Tags and Metadata carry the business context, e.g. the order or
+	// campaign the notification belongs to.
+	ClientID   string
+	ExternalID string
+	Tags       []string
+	Metadata   map[string]any
+	// ChannelOptions holds channel-specific delivery settings keyed by
+	// channel, e.g. {"telegram": {...}, "email": {...}}; each part is in the
+	// format understood by its channel. Notifications read from the repository
+	// or the cache carry the options without the email AttachmentsOption.
+	ChannelOptions json.RawMessage
+	// SuppressionReason is set together with StatusSuppressed.
+	SuppressionReason string
+	// HTMLMessage is filled from the template right before sending and is not
+	// persisted.
+	HTMLMessage string
+	// Address is where the notification is delivered on its channel. It is
+	// resolved from the recipient directory right before sending and is not
+	// persisted.
+	Address   string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	// Revision grows with every change of the row and orders cache writes, so
+	// an older copy never replaces a newer one.
+	Revision int64
+}
+
+// ParseChannelOptions splits options into the parts of each channel.
+func ParseChannelOptions(options json.RawMessage) (map[NotificationChannel]json.RawMessage, error) {
+	if len(options) == 0 {
+		return nil, nil
+	}
+	var parts map[NotificationChannel]json.RawMessage
+	if err := json.Unmarshal(options, &parts); err != nil {
+		return nil, fmt.Errorf("%w: expected an object keyed by channel: %v", ErrInvalidOptions, err)
+	}
+	return parts, nil
+}
+
+// OptionsFor returns the part of options for channel, or nil if there is none.
+func OptionsFor(options json.RawMessage, channel NotificationChannel) json.RawMessage {
+	parts, err := ParseChannelOptions(options)
+	if err != nil {
+		return nil
+	}
+	return parts[channel]
+}
+
+// AttachmentsOption is the field of the email options with attachments. They
+// take up to 10 MB, so only the sender loads them; listings, API responses and
+// the cache go without.
+const AttachmentsOption = "attachments"
+
+// WithoutAttachments returns the options with the email attachments removed.
+func WithoutAttachments(options json.RawMessage) json.RawMessage {
+	parts, err := ParseChannelOptions(options)
+	if err != nil {
+		return options
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(parts[ChannelEmail], &fields) != nil {
+		return options
+	}
+	if _, ok := fields[AttachmentsOption]; !ok {
+		return options
+	}
+	delete(fields, AttachmentsOption)
+	email, err := json.Marshal(fields)
+	if err != nil {
+		return options
+	}
+	parts[ChannelEmail] = email
+	stripped, err := json.Marshal(parts)
+	if err != nil {
+		return options
+	}
+	return stripped
 }
 
 type CreateNotification struct {
-	UserID  string
-	Channel NotificationChannel
-	Message string
-	SendAt  time.Time
+	UserID         string
+	Channel        NotificationChannel
+	Channels       []NotificationChannel
+	Category       string
+	Subject        string
+	Message        string
+	SendAt         time.Time
+	Recurrence     *Recurrence
+	TemplateID     string
+	TemplateParams map[string]any
+	ChannelOptions json.RawMessage
+	IdempotencyKey string
+	ClientID       string
+	ExternalID     string
+	Tags           []string
+	Metadata       map[string]any
+	// RequestHash fingerprints the client's request; a replay with the same
+	// idempotency key must carry the same hash.
+	RequestHash string
+}
+
+// BatchResult is the outcome of one item of a batch create: the created
+// notification or the reason the item was rejected.
+type BatchResult struct {
+	Notification *Notification
+	Err          error
+}
+
+// UpdateNotification changes a pending notification. Channels, when set,
+// replaces the whole fallback chain and Channel is its first element.
+type UpdateNotification struct {
+	Channel  *NotificationChannel
+	Channels []NotificationChannel
+	Message  *string
+	SendAt   *time.Time
 }
 
 var (
-	ErrSendAtInPast   = errors.New("send_at must be in the future")
-	ErrNotFound       = errors.New("notification not found")
-	ErrCannotCancel   = errors.New("cannot cancel non-pending notification")
-	ErrUnknownChannel = errors.New("unknown notification channel")
+	ErrSendAtInPast     = errors.New("send_at must be in the future")
+	ErrNotFound         = errors.New("notification not found")
+	ErrCannotCancel     = errors.New("cannot cancel non-pending notification")
+	ErrCannotUpdate     = errors.New("cannot update non-pending notification")
+	ErrNothingToUpdate  = errors.New("no fields to update")
+	ErrInvalidCursor    = errors.New("invalid cursor")
+	ErrUnknownChannel   = errors.New("unknown notification channel")
+	ErrInvalidRecipient = errors.New("invalid recipient for channel")
+	ErrInvalidOptions   = errors.New("invalid channel options")
+	ErrBatchRecurrence  = errors.New("recurring notifications cannot be created in a batch")
+	ErrEmptyFilter      = errors.New("at least one filter field is required")
+	ErrChainUpdate      = errors.New("notification has a fallback chain, update channels instead of channel")
+	ErrOccurrenceExists = errors.New("series already has an occurrence at this time")
+
+	ErrIdempotencyConflict  = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+	ErrExternalIDExists     = errors.New("external_id already exists for this client")
 )
+
+// NextChannel returns the channel that follows the current one in the fallback
+// chain.
+func (n *Notification) NextChannel() (NotificationChannel, bool) {
+	for i, ch := range n.Channels {
+		if ch == n.Channel && i+1 < len(n.Channels) {
+			return n.Channels[i+1], true
+		}
+	}
+	return "", false
+}
+
+type ChannelInfo struct {
+	Name      NotificationChannel
+	Recipient string
+}
+
+// CancelFilter selects pending notifications for a bulk cancel. Set fields
+// are combined with AND; at least one must be set. A notification matches
+// Tags when it has all of them and Metadata when every key has the given
+// value.
+type CancelFilter struct {
+	UserID   string
+	Channel  NotificationChannel
+	IDs      []string
+	Tags     []string
+	Metadata map[string]string
+}
+
+func (f CancelFilter) IsEmpty() bool {
+	return f.UserID == "" && f.Channel == "" && len(f.IDs) == 0 && len(f.Tags) == 0 && len(f.Metadata) == 0
+}
+
+type ListCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+type ListFilter struct {
+	Status      NotificationStatus
+	Channel     NotificationChannel
+	UserID      string
+	ClientID    string
+	ExternalID  string
+	Tags        []string
+	Metadata    map[string]string
+	SendAtFrom  *time.Time
+	SendAtTo    *time.Time
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Limit       int
+	Cursor      *ListCursor
+}
+
+type NotificationPage struct {
+	Notifications []*Notification
+	NextCursor    *ListCursor
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWithoutAttachments(t *testing.T) {
+	tests := map[string]struct {
+		options string
+		want    string
+	}{
+		"attachments removed": {`{"email":{"attachments":[{"filename":"a.txt","content":"eA=="}]},"telegram":{"parse_mode":"HTML"}}`, `{"email":{},"telegram":{"parse_mode":"HTML"}}`},
+		"other channels kept": {`{"telegram":{"attachments":"x"}}`, `{"telegram":{"attachments":"x"}}`},
+		"null":                {`null`, `null`},
+		"empty":               {``, ``},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := WithoutAttachments(json.RawMessage(tt.options)); string(got) != tt.want {
+				t.Fatalf("WithoutAttachments() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionsFor(t *testing.T) {
+	options := json.RawMessage(`{"telegram":{"parse_mode":"HTML"},"email":{"attachments":[]}}`)
+	if got := OptionsFor(options, ChannelTelegram); string(got) != `{"parse_mode":"HTML"}` {
+		t.Fatalf("OptionsFor(telegram) = %s", got)
+	}
+	if got := OptionsFor(options, ChannelWebhook); got != nil {
+		t.Fatalf("OptionsFor(webhook) = %s, want nil", got)
+	}
+	if got := OptionsFor(json.RawMessage(`[1]`), ChannelTelegram); got != nil {
+		t.Fatalf("OptionsFor() on invalid options = %s, want nil", got)
+	}
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+type OutboxEntry struct {
+	ID             int64
+	NotificationID string
+	Version        int
+	PublishAt      time.Time
+	Attempts       int
+	CreatedAt      time.Time
+}
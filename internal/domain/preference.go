@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Preferences are the delivery rules a user set for themselves. Quiet hours
+// are interpreted in Timezone.
+type Preferences struct {
+	UserID             string
+	Timezone           string
+	QuietHours         *QuietHours
+	OptedOutChannels   []NotificationChannel
+	OptedOutCategories []string
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+type SavePreferences struct {
+	Timezone           string
+	QuietHours         *QuietHours
+	OptedOutChannels   []NotificationChannel
+	OptedOutCategories []string
+}
+
+// QuietHours is a daily window given in minutes since local midnight. A window
+// whose end is before its start spans midnight, e.g. 22:00-08:00.
+type QuietHours struct {
+	Start int
+	End   int
+}
+
+// EndAfter reports the end of the window that t falls into.
+func (q QuietHours) EndAfter(t time.Time) (time.Time, bool) {
+	minute := t.Hour()*60 + t.Minute()
+	var inside bool
+	if q.Start < q.End {
+		inside = minute >= q.Start && minute < q.End
+	} else {
+		inside = minute >= q.Start || minute < q.End
+	}
+	if !inside {
+		return time.Time{}, false
+	}
+	end := time.Date(t.Year(), t.Month(), t.Day(), q.End/60, q.End%60, 0, 0, t.Location())
+	if !end.After(t) {
+		end = time.Date(t.Year(), t.Month(), t.Day()+1, q.End/60, q.End%60, 0, 0, t.Location())
+	}
+	return end, true
+}
+
+type DeliveryAction string
+
+const (
+	DeliverNow DeliveryAction = "deliver"
+	// DeliverLater postpones the send until DeliveryDecision.Until.
+	DeliverLater DeliveryAction = "defer"
+	// SkipChannel means the user opted out of the channel but may still be
+	// reached through the next channel of the fallback chain.
+	SkipChannel DeliveryAction = "skip_channel"
+	Suppress    DeliveryAction = "suppress"
+)
+
+type DeliveryDecision struct {
+	Action DeliveryAction
+	Until  time.Time
+	Reason string
+}
+
+var (
+	ErrPreferencesNotFound = errors.New("preferences not found")
+	ErrInvalidPreferences  = errors.New("invalid preferences")
+)
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursEndAfter(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overnight := QuietHours{Start: 22 * 60, End: 8 * 60}
+	daytime := QuietHours{Start: 13 * 60, End: 14*60 + 30}
+	tests := []struct {
+		name string
+		q    QuietHours
+		t    time.Time
+		want time.Time // zero when t is outside the window
+	}{
+		{name: "before midnight", q: overnight, t: time.Date(2030, 1, 1, 23, 15, 0, 0, moscow), want: time.Date(2030, 1, 2, 8, 0, 0, 0, moscow)},
+		{name: "after midnight", q: overnight, t: time.Date(2030, 1, 2, 3, 0, 0, 0, moscow), want: time.Date(2030, 1, 2, 8, 0, 0, 0, moscow)},
+		{name: "start is inside", q: overnight, t: time.Date(2030, 1, 1, 22, 0, 0, 0, moscow), want: time.Date(2030, 1, 2, 8, 0, 0, 0, moscow)},
+		{name: "end is outside", q: overnight, t: time.Date(2030, 1, 2, 8, 0, 0, 0, moscow)},
+		{name: "daytime window", q: daytime, t: time.Date(2030, 1, 1, 13, 45, 0, 0, moscow), want: time.Date(2030, 1, 1, 14, 30, 0, 0, moscow)},
+		{name: "outside daytime window", q: daytime, t: time.Date(2030, 1, 1, 20, 0, 0, 0, moscow)},
+		{
+			// Clocks move forward at 02:00 on 2030-03-10, so the night is an
+			// hour shorter in absolute time.
+			name: "across daylight saving change",
+			q:    overnight,
+			t:    time.Date(2030, 3, 9, 23, 0, 0, 0, newYork),
+			want: time.Date(2030, 3, 10, 12, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.q.EndAfter(tt.t)
+			if tt.want.IsZero() {
+				if ok {
+					t.Fatalf("EndAfter() = %v, want outside the window", got)
+				}
+				return
+			}
+			if !ok || !got.Equal(tt.want) {
+				t.Fatalf("EndAfter() = %v, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Recipient maps an internal user ID to an address per channel, for example
+// an email address, a Telegram chat ID or a webhook URL.
+type Recipient struct {
+	UserID            string
+	Addresses         map[NotificationChannel]string
+	PreferredChannels []NotificationChannel
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+type SaveRecipient struct {
+	Addresses         map[NotificationChannel]string
+	PreferredChannels []NotificationChannel
+}
+
+var (
+	ErrRecipientNotFound = errors.New("recipient not found")
+	ErrNoAddress         = errors.New("recipient has no address for channel")
+	ErrChannelRequired   = errors.New("channel is required for users without preferred channels")
+)
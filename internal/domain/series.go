@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+type RecurrenceKind string
+
+const (
+	RecurrenceCron  RecurrenceKind = "cron"
+	RecurrenceRRule RecurrenceKind = "rrule"
+)
+
+type Recurrence struct {
+	Kind       RecurrenceKind
+	Expression string
+	Timezone   string
+	Until      *time.Time
+}
+
+type SeriesStatus string
+
+const (
+	SeriesActive    SeriesStatus = "active"
+	SeriesCancelled SeriesStatus = "cancelled"
+	SeriesFinished  SeriesStatus = "finished"
+)
+
+type Series struct {
+	ID         string
+	Recurrence Recurrence
+	StartsAt   time.Time
+	Status     SeriesStatus
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+var (
+	ErrInvalidRecurrence = errors.New("invalid recurrence")
+	ErrNoOccurrences     = errors.New("recurrence has no future occurrences")
+)
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// Template bodies are Go templates. ChannelBodies replaces TextBody for the
+// channels it lists, e.g. with a shorter Telegram text; HTMLBody is the HTML
+// alternative for channels that send HTML.
+type Template struct {
+	ID            string
+	Name          string
+	TextBody      string
+	HTMLBody      string
+	ChannelBodies map[NotificationChannel]string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type SaveTemplate struct {
+	Name          string
+	TextBody      string
+	HTMLBody      string
+	ChannelBodies map[NotificationChannel]string
+}
+
+type RenderedMessage struct {
+	Text string
+	HTML string
+}
+
+var (
+	ErrTemplateNotFound      = errors.New("template not found")
+	ErrTemplateExists        = errors.New("template with this name already exists")
+	ErrInvalidTemplate       = errors.New("invalid template")
+	ErrTemplateParams        = errors.New("template params do not match template")
+	ErrTemplateInUse         = errors.New("template is used by pending notifications")
+	ErrMessageOrTemplateOnly = errors.New("either message or template_id must be set, not both")
+	ErrTemplateMessage       = errors.New("message of a notification rendered from a template cannot be changed")
+)
@@ -2,13 +2,48 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
+
 	"delayed-notifier/internal/domain"
 )
 
 type NotificationService interface {
-	CreateNotification(ctx context.Context, notification *domain.CreateNotification) (*domain.Notification, error)
-	GetNotificationStatus(ctx context.Context, id string) (domain.NotificationStatus, error)
+	CreateNotification(ctx context.Context, notification *domain.CreateNotification) (*domain.Notification, bool, error)
+	CreateNotifications(ctx context.Context, items []*domain.CreateNotification) ([]domain.BatchResult, error)
+	GetNotification(ctx context.Context, id string) (*domain.Notification, error)
+	ListAttempts(ctx context.Context, id string) ([]*domain.DeliveryAttempt, error)
 	CancelNotification(ctx context.Context, id string) error
-	ListNotifications(ctx context.Context) ([]*domain.Notification, error)
-	ProcessNotification(ctx context.Context, id string) error
+	CancelNotifications(ctx context.Context, filter domain.CancelFilter) (int, error)
+	UpdateNotification(ctx context.Context, id string, upd *domain.UpdateNotification) (*domain.Notification, error)
+	ListNotifications(ctx context.Context, filter domain.ListFilter) (*domain.NotificationPage, error)
+	ProcessNotification(ctx context.Context, id string, version int) error
+}
+
+type TemplateService interface {
+	CreateTemplate(ctx context.Context, tmpl *domain.SaveTemplate) (*domain.Template, error)
+	GetTemplate(ctx context.Context, id string) (*domain.Template, error)
+	ListTemplates(ctx context.Context) ([]*domain.Template, error)
+	UpdateTemplate(ctx context.Context, id string, tmpl *domain.SaveTemplate) (*domain.Template, error)
+	DeleteTemplate(ctx context.Context, id string) error
+}
+
+type ChannelRegistry interface {
+	Channels() []domain.ChannelInfo
+	Supports(name domain.NotificationChannel) bool
+	ValidateRecipient(name domain.NotificationChannel, recipient string) error
+	ValidateOptions(raw json.RawMessage) error
+}
+
+type RecipientService interface {
+	SaveRecipient(ctx context.Context, userID string, rcpt *domain.SaveRecipient) (*domain.Recipient, error)
+	GetRecipient(ctx context.Context, userID string) (*domain.Recipient, error)
+	DeleteRecipient(ctx context.Context, userID string) error
+	LoadRecipients(ctx context.Context, userIDs []string) (map[string]*domain.Recipient, error)
+	ResolveChannel(rcpt *domain.Recipient, userID string, channel domain.NotificationChannel) (domain.NotificationChannel, string, error)
+}
+
+type PreferenceService interface {
+	SavePreferences(ctx context.Context, userID string, pref *domain.SavePreferences) (*domain.Preferences, error)
+	GetPreferences(ctx context.Context, userID string) (*domain.Preferences, error)
+	DeletePreferences(ctx context.Context, userID string) error
 }
@@ -0,0 +1,83 @@
+package dto
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := &domain.ListCursor{
+		CreatedAt: time.Date(2030, 1, 2, 3, 4, 5, 123456000, time.FixedZone("MSK", 3*60*60)),
+		ID:        "6f1c2a9e-3b7d-4c55-9a51-0f2f4b8d1e77",
+	}
+	encoded := EncodeCursor(want)
+	if strings.ContainsAny(encoded, "+/=") {
+		t.Fatalf("cursor %q is not URL-safe", encoded)
+	}
+	got, err := DecodeCursor(encoded)
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if got.ID != want.ID || !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Fatalf("DecodeCursor() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEncodeNilCursor(t *testing.T) {
+	if got := EncodeCursor(nil); got != "" {
+		t.Fatalf("EncodeCursor(nil) = %q, want empty", got)
+	}
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	tests := map[string]string{
+		"not base64":   "!!!",
+		"not json":     "bm90IGpzb24",
+		"missing id":   EncodeCursor(&domain.ListCursor{CreatedAt: time.Now()}),
+		"padded input": EncodeCursor(&domain.ListCursor{ID: "a"}) + "==",
+	}
+	for name, cursor := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := DecodeCursor(cursor); !errors.Is(err, domain.ErrInvalidCursor) {
+				t.Fatalf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", cursor, err)
+			}
+		})
+	}
+}
+
+func TestListQueryCursor(t *testing.T) {
+	cursor := &domain.ListCursor{CreatedAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), ID: "id-1"}
+	q, err := ParseListQuery(url.Values{"cursor": {EncodeCursor(cursor)}, "limit": {"10"}})
+	if err != nil {
+		t.Fatalf("ParseListQuery() error = %v", err)
+	}
+	filter, err := ListQueryToDomain(q)
+	if err != nil {
+		t.Fatalf("ListQueryToDomain() error = %v", err)
+	}
+	if filter.Limit != 10 || filter.Cursor == nil || filter.Cursor.ID != "id-1" || !filter.Cursor.CreatedAt.Equal(cursor.CreatedAt) {
+		t.Fatalf("ListQueryToDomain() = %+v", filter)
+	}
+
+	q.Cursor = "garbage"
+	if _, err := ListQueryToDomain(q); !errors.Is(err, domain.ErrInvalidCursor) {
+		t.Fatalf("ListQueryToDomain() error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestFromDomainPageNextCursor(t *testing.T) {
+	next := &domain.ListCursor{CreatedAt: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), ID: "last"}
+	resp := FromDomainPage(&domain.NotificationPage{NextCursor: next})
+	got, err := DecodeCursor(resp.NextCursor)
+	if err != nil || got.ID != "last" {
+		t.Fatalf("next cursor %q decodes to %+v, %v", resp.NextCursor, got, err)
+	}
+	if resp := FromDomainPage(&domain.NotificationPage{}); resp.NextCursor != "" || resp.Notifications == nil {
+		t.Fatalf("last page = %+v, want empty cursor and non-nil list", resp)
+	}
+}
@@ -1,61 +1,427 @@
 package dto
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"delayed-notifier/internal/domain"
 )
 
+// metadataParamPrefix marks list query parameters that filter by metadata,
+// e.g. metadata.order_id=42.
+const metadataParamPrefix = "metadata."
+
 type CreateNotificationRequest struct {
-	UserID  string `json:"user_id" validate:"required"`
-	Channel string `json:"channel" validate:"required,oneof=email telegram"`
-	Message string `json:"message" validate:"required"`
-	SendAt  string `json:"send_at" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	UserID     string             `json:"user_id" validate:"required"`
+	Channel    string             `json:"channel,omitempty" validate:"omitempty,excluded_with=Channels,channel"`
+	Channels   []string           `json:"channels,omitempty" validate:"omitempty,unique,dive,channel"`
+	Category   string             `json:"category,omitempty" validate:"omitempty,max=100"`
+	Subject    string             `json:"subject,omitempty" validate:"omitempty,max=255"`
+	Message    string             `json:"message" validate:"required_without=TemplateID,excluded_with=TemplateID"`
+	TemplateID string             `json:"template_id,omitempty"`
+	Params     map[string]any     `json:"params,omitempty"`
+	Options    json.RawMessage    `json:"options,omitempty"`
+	SendAt     string             `json:"send_at" validate:"required_without=Recurrence,omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Recurrence *RecurrenceRequest `json:"recurrence,omitempty"`
+	ExternalID string             `json:"external_id,omitempty" validate:"omitempty,max=255"`
+	Tags       []string           `json:"tags,omitempty" validate:"omitempty,max=20,unique,dive,required,max=50"`
+	Metadata   map[string]any     `json:"metadata,omitempty"`
+}
+
+type BatchCreateRequest struct {
+	Notifications []CreateNotificationRequest `json:"notifications" validate:"required,min=1"`
+}
+
+type BatchItemResponse struct {
+	Index int    `json:"index"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type BatchCreateResponse struct {
+	Created int                 `json:"created"`
+	Failed  int                 `json:"failed"`
+	Results []BatchItemResponse `json:"results"`
+}
+
+type RecurrenceRequest struct {
+	Type       string `json:"type" validate:"required,oneof=cron rrule"`
+	Expression string `json:"expression" validate:"required"`
+	Timezone   string `json:"timezone,omitempty"`
+	Until      string `json:"until,omitempty" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+type CancelNotificationsRequest struct {
+	UserID   string            `json:"user_id,omitempty"`
+	Channel  string            `json:"channel,omitempty"`
+	IDs      []string          `json:"ids,omitempty" validate:"omitempty,max=1000,dive,required"`
+	Tags     []string          `json:"tags,omitempty" validate:"omitempty,dive,required"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type CancelNotificationsResponse struct {
+	Cancelled int `json:"cancelled"`
+}
+
+type UpdateNotificationRequest struct {
+	Channel  *string  `json:"channel,omitempty" validate:"omitempty,excluded_with=Channels,channel"`
+	Channels []string `json:"channels,omitempty" validate:"omitempty,unique,dive,channel"`
+	Message  *string  `json:"message,omitempty" validate:"omitempty,min=1"`
+	SendAt   *string  `json:"send_at,omitempty" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
 }
 
 type NotificationResponse struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
-	Channel   string    `json:"channel"`
-	Message   string    `json:"message"`
-	SendAt    time.Time `json:"send_at"`
-	Status    string    `json:"status"`
-	Retries   int       `json:"retries"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         string            `json:"id"`
+	UserID     string            `json:"user_id"`
+	Channel    string            `json:"channel"`
+	Channels   []string          `json:"channels,omitempty"`
+	Category   string            `json:"category,omitempty"`
+	Subject    string            `json:"subject,omitempty"`
+	Message    string            `json:"message"`
+	TemplateID string            `json:"template_id,omitempty"`
+	Params     map[string]any    `json:"params,omitempty"`
+	Options    json.RawMessage   `json:"options,omitempty"`
+	SendAt     time.Time         `json:"send_at"`
+	Status     string            `json:"status"`
+	Reason     string            `json:"suppression_reason,omitempty"`
+	Retries    int               `json:"retries"`
+	Version    int               `json:"version"`
+	SeriesID   string            `json:"series_id,omitempty"`
+	ClientID   string            `json:"client_id,omitempty"`
+	ExternalID string            `json:"external_id,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+	Metadata   map[string]any    `json:"metadata,omitempty"`
+	Attempts   []AttemptResponse `json:"attempts,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// ListNotificationsQuery checks Channel against every known channel rather
+// than the enabled ones, so notifications of a channel switched off later can
+// still be listed.
+type ListNotificationsQuery struct {
+	Status      string `validate:"omitempty,oneof=pending processing sent cancelled failed suppressed"`
+	Channel     string `validate:"omitempty,oneof=email telegram webhook"`
+	UserID      string
+	ClientID    string
+	ExternalID  string
+	Tags        []string
+	Metadata    map[string]string
+	SendAtFrom  string `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	SendAtTo    string `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedFrom string `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedTo   string `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Limit       int    `validate:"gte=0,lte=500"`
+	Cursor      string
+}
+
+type ListNotificationsResponse struct {
+	Notifications []NotificationResponse `json:"notifications"`
+	NextCursor    string                 `json:"next_cursor,omitempty"`
+}
+
+type ChannelResponse struct {
+	Name      string `json:"name"`
+	Recipient string `json:"recipient"`
 }
 
-type StatusResponse struct {
-	ID     string `json:"id"`
-	Status string `json:"status"`
+type AttemptResponse struct {
+	StartedAt    time.Time `json:"started_at"`
+	Channel      string    `json:"channel"`
+	DurationMs   int64     `json:"duration_ms"`
+	Success      bool      `json:"success"`
+	Error        string    `json:"error,omitempty"`
+	ProviderCode string    `json:"provider_code,omitempty"`
 }
 
 func FromDomain(n *domain.Notification) NotificationResponse {
-	return NotificationResponse{
-		ID:        n.ID,
-		UserID:    n.UserID,
-		Channel:   string(n.Channel),
-		Message:   n.Message,
-		SendAt:    n.SendAt,
-		Status:    string(n.Status),
-		Retries:   n.Retries,
-		CreatedAt: n.CreatedAt,
-		UpdatedAt: n.UpdatedAt,
+	resp := NotificationResponse{
+		ID:         n.ID,
+		UserID:     n.UserID,
+		Channel:    string(n.Channel),
+		Category:   n.Category,
+		Subject:    n.Subject,
+		Message:    n.Message,
+		TemplateID: n.TemplateID,
+		Params:     n.TemplateParams,
+		Options:    domain.WithoutAttachments(n.ChannelOptions),
+		SendAt:     n.SendAt,
+		Status:     string(n.Status),
+		Reason:     n.SuppressionReason,
+		Retries:    n.Retries,
+		Version:    n.Version,
+		SeriesID:   n.SeriesID,
+		ClientID:   n.ClientID,
+		ExternalID: n.ExternalID,
+		Tags:       n.Tags,
+		Metadata:   n.Metadata,
+		CreatedAt:  n.CreatedAt,
+		UpdatedAt:  n.UpdatedAt,
+	}
+	for _, ch := range n.Channels {
+		resp.Channels = append(resp.Channels, string(ch))
+	}
+	return resp
+}
+
+func FromDomainChannels(channels []domain.ChannelInfo) []ChannelResponse {
+	resp := make([]ChannelResponse, 0, len(channels))
+	for _, ch := range channels {
+		resp = append(resp, ChannelResponse{
+			Name:      string(ch.Name),
+			Recipient: ch.Recipient,
+		})
+	}
+	return resp
+}
+
+func FromDomainAttempts(attempts []*domain.DeliveryAttempt) []AttemptResponse {
+	resp := make([]AttemptResponse, 0, len(attempts))
+	for _, a := range attempts {
+		resp = append(resp, AttemptResponse{
+			StartedAt:    a.StartedAt,
+			Channel:      string(a.Channel),
+			DurationMs:   a.Duration.Milliseconds(),
+			Success:      a.Success,
+			Error:        a.Error,
+			ProviderCode: a.ProviderCode,
+		})
 	}
+	return resp
 }
 
 func ToDomain(req CreateNotificationRequest) (*domain.CreateNotification, error) {
-	sendAt, err := time.Parse(time.RFC3339, req.SendAt)
-	if err != nil {
-		return nil, err
+	var sendAt time.Time
+	if req.SendAt != "" {
+		var err error
+		sendAt, err = time.Parse(time.RFC3339, req.SendAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var rec *domain.Recurrence
+	if req.Recurrence != nil {
+		rec = &domain.Recurrence{
+			Kind:       domain.RecurrenceKind(req.Recurrence.Type),
+			Expression: req.Recurrence.Expression,
+			Timezone:   req.Recurrence.Timezone,
+		}
+		if req.Recurrence.Until != "" {
+			until, err := time.Parse(time.RFC3339, req.Recurrence.Until)
+			if err != nil {
+				return nil, err
+			}
+			rec.Until = &until
+		}
+	}
+	create := &domain.CreateNotification{
+		UserID:         req.UserID,
+		Channel:        domain.NotificationChannel(req.Channel),
+		Category:       req.Category,
+		Subject:        req.Subject,
+		Message:        req.Message,
+		TemplateID:     req.TemplateID,
+		TemplateParams: req.Params,
+		ChannelOptions: req.Options,
+		SendAt:         sendAt,
+		Recurrence:     rec,
+		ExternalID:     req.ExternalID,
+		Tags:           req.Tags,
+		Metadata:       req.Metadata,
+	}
+	for _, ch := range req.Channels {
+		create.Channels = append(create.Channels, domain.NotificationChannel(ch))
+	}
+	if len(create.Channels) > 0 {
+		create.Channel = create.Channels[0]
+	}
+	return create, nil
+}
+
+// Fingerprint hashes the fields the client sent, scoped to its client ID, to
+// tell an idempotent replay from another request reusing the same key. Empty
+// fields are left out so that new optional request fields do not change the
+// fingerprint of requests that do not use them.
+func Fingerprint(req CreateNotificationRequest, clientID string) string {
+	if sendAt, err := time.Parse(time.RFC3339, req.SendAt); err == nil {
+		req.SendAt = sendAt.UTC().Format(time.RFC3339Nano)
 	}
-	if sendAt.Before(time.Now()) {
-		return nil, domain.ErrSendAtInPast
+	data, _ := json.Marshal(req)
+	var fields map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	dec.Decode(&fields)
+	for k, v := range fields {
+		switch v := v.(type) {
+		case nil:
+			delete(fields, k)
+		case string:
+			if v == "" {
+				delete(fields, k)
+			}
+		case []any:
+			if len(v) == 0 {
+				delete(fields, k)
+			}
+		case map[string]any:
+			if len(v) == 0 {
+				delete(fields, k)
+			}
+		}
 	}
-	return &domain.CreateNotification{
-		UserID:  req.UserID,
-		Channel: domain.NotificationChannel(req.Channel),
+	if clientID != "" {
+		fields["client_id"] = clientID
+	}
+	// Maps are encoded with sorted keys, which makes the encoding canonical.
+	data, _ = json.Marshal(fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func CancelToDomain(req CancelNotificationsRequest) domain.CancelFilter {
+	return domain.CancelFilter{
+		UserID:   req.UserID,
+		Channel:  domain.NotificationChannel(req.Channel),
+		IDs:      req.IDs,
+		Tags:     req.Tags,
+		Metadata: req.Metadata,
+	}
+}
+
+func UpdateToDomain(req UpdateNotificationRequest) (*domain.UpdateNotification, error) {
+	upd := &domain.UpdateNotification{
 		Message: req.Message,
-		SendAt:  sendAt,
-	}, nil
+	}
+	if req.Channel != nil {
+		upd.Channels = []domain.NotificationChannel{domain.NotificationChannel(*req.Channel)}
+	}
+	for _, ch := range req.Channels {
+		upd.Channels = append(upd.Channels, domain.NotificationChannel(ch))
+	}
+	if len(upd.Channels) > 0 {
+		upd.Channel = &upd.Channels[0]
+	}
+	if req.SendAt != nil {
+		sendAt, err := time.Parse(time.RFC3339, *req.SendAt)
+		if err != nil {
+			return nil, err
+		}
+		upd.SendAt = &sendAt
+	}
+	return upd, nil
+}
+
+func ParseListQuery(values url.Values) (ListNotificationsQuery, error) {
+	q := ListNotificationsQuery{
+		Status:      values.Get("status"),
+		Channel:     values.Get("channel"),
+		UserID:      values.Get("user_id"),
+		ClientID:    values.Get("client_id"),
+		ExternalID:  values.Get("external_id"),
+		Tags:        values["tag"],
+		SendAtFrom:  values.Get("send_at_from"),
+		SendAtTo:    values.Get("send_at_to"),
+		CreatedFrom: values.Get("created_from"),
+		CreatedTo:   values.Get("created_to"),
+		Cursor:      values.Get("cursor"),
+	}
+	for key := range values {
+		if name, ok := strings.CutPrefix(key, metadataParamPrefix); ok && name != "" {
+			if q.Metadata == nil {
+				q.Metadata = make(map[string]string)
+			}
+			q.Metadata[name] = values.Get(key)
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return q, err
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+func ListQueryToDomain(q ListNotificationsQuery) (domain.ListFilter, error) {
+	filter := domain.ListFilter{
+		Status:     domain.NotificationStatus(q.Status),
+		Channel:    domain.NotificationChannel(q.Channel),
+		UserID:     q.UserID,
+		ClientID:   q.ClientID,
+		ExternalID: q.ExternalID,
+		Tags:       q.Tags,
+		Metadata:   q.Metadata,
+		Limit:      q.Limit,
+	}
+	ranges := []struct {
+		value string
+		dst   **time.Time
+	}{
+		{q.SendAtFrom, &filter.SendAtFrom},
+		{q.SendAtTo, &filter.SendAtTo},
+		{q.CreatedFrom, &filter.CreatedFrom},
+		{q.CreatedTo, &filter.CreatedTo},
+	}
+	for _, rng := range ranges {
+		if rng.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, rng.value)
+		if err != nil {
+			return filter, err
+		}
+		*rng.dst = &t
+	}
+	if q.Cursor != "" {
+		cursor, err := DecodeCursor(q.Cursor)
+		if err != nil {
+			return filter, err
+		}
+		filter.Cursor = cursor
+	}
+	return filter, nil
+}
+
+type cursorPayload struct {
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"i"`
+}
+
+func EncodeCursor(c *domain.ListCursor) string {
+	if c == nil {
+		return ""
+	}
+	data, _ := json.Marshal(cursorPayload{CreatedAt: c.CreatedAt, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (*domain.ListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+	var p cursorPayload
+	if err := json.Unmarshal(data, &p); err != nil || p.ID == "" {
+		return nil, domain.ErrInvalidCursor
+	}
+	return &domain.ListCursor{CreatedAt: p.CreatedAt, ID: p.ID}, nil
+}
+
+func FromDomainPage(page *domain.NotificationPage) ListNotificationsResponse {
+	resp := ListNotificationsResponse{
+		Notifications: make([]NotificationResponse, 0, len(page.Notifications)),
+		NextCursor:    EncodeCursor(page.NextCursor),
+	}
+	for _, n := range page.Notifications {
+		resp.Notifications = append(resp.Notifications, FromDomain(n))
+	}
+	return resp
 }
@@ -0,0 +1,28 @@
+package dto
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+func TestListQueryValidatesChannel(t *testing.T) {
+	validate := validator.New()
+	tests := map[string]bool{
+		"":         true,
+		"email":    true,
+		"webhook":  true,
+		"telegarm": false,
+		"sms":      false,
+	}
+	for channel, valid := range tests {
+		q, err := ParseListQuery(url.Values{"channel": {channel}})
+		if err != nil {
+			t.Fatalf("ParseListQuery() error = %v", err)
+		}
+		if err := validate.Struct(q); (err == nil) != valid {
+			t.Errorf("channel %q: validation error = %v, want valid = %v", channel, err, valid)
+		}
+	}
+}
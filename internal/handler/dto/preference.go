@@ -0,0 +1,91 @@
+package dto
+
+import (
+	"fmt"
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+const clockLayout = "15:04"
+
+type SavePreferencesRequest struct {
+	Timezone           string             `json:"timezone,omitempty"`
+	QuietHours         *QuietHoursRequest `json:"quiet_hours,omitempty"`
+	OptedOutChannels   []string           `json:"opted_out_channels,omitempty" validate:"omitempty,unique,dive,channel"`
+	OptedOutCategories []string           `json:"opted_out_categories,omitempty" validate:"omitempty,unique,dive,required,max=100"`
+}
+
+// QuietHoursRequest holds local wall-clock times in HH:MM format.
+type QuietHoursRequest struct {
+	Start string `json:"start" validate:"required"`
+	End   string `json:"end" validate:"required"`
+}
+
+type PreferencesResponse struct {
+	UserID             string             `json:"user_id"`
+	Timezone           string             `json:"timezone"`
+	QuietHours         *QuietHoursRequest `json:"quiet_hours,omitempty"`
+	OptedOutChannels   []string           `json:"opted_out_channels"`
+	OptedOutCategories []string           `json:"opted_out_categories"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+}
+
+func PreferencesToDomain(req SavePreferencesRequest) (*domain.SavePreferences, error) {
+	pref := &domain.SavePreferences{
+		Timezone:           req.Timezone,
+		OptedOutCategories: req.OptedOutCategories,
+	}
+	for _, ch := range req.OptedOutChannels {
+		pref.OptedOutChannels = append(pref.OptedOutChannels, domain.NotificationChannel(ch))
+	}
+	if req.QuietHours != nil {
+		start, err := parseClock(req.QuietHours.Start)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(req.QuietHours.End)
+		if err != nil {
+			return nil, err
+		}
+		pref.QuietHours = &domain.QuietHours{Start: start, End: end}
+	}
+	return pref, nil
+}
+
+func FromDomainPreferences(p *domain.Preferences) PreferencesResponse {
+	resp := PreferencesResponse{
+		UserID:             p.UserID,
+		Timezone:           p.Timezone,
+		OptedOutChannels:   make([]string, 0, len(p.OptedOutChannels)),
+		OptedOutCategories: p.OptedOutCategories,
+		CreatedAt:          p.CreatedAt,
+		UpdatedAt:          p.UpdatedAt,
+	}
+	if resp.OptedOutCategories == nil {
+		resp.OptedOutCategories = []string{}
+	}
+	for _, ch := range p.OptedOutChannels {
+		resp.OptedOutChannels = append(resp.OptedOutChannels, string(ch))
+	}
+	if p.QuietHours != nil {
+		resp.QuietHours = &QuietHoursRequest{
+			Start: formatClock(p.QuietHours.Start),
+			End:   formatClock(p.QuietHours.End),
+		}
+	}
+	return resp
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse(clockLayout, s)
+	if err != nil {
+		return 0, fmt.Errorf("%w: expected HH:MM, got %q", domain.ErrInvalidPreferences, s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
@@ -0,0 +1,51 @@
+package dto
+
+import (
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+type SaveRecipientRequest struct {
+	Addresses         map[string]string `json:"addresses" validate:"required,min=1,dive,keys,channel,endkeys,required"`
+	PreferredChannels []string          `json:"preferred_channels,omitempty" validate:"omitempty,unique,dive,channel"`
+}
+
+type RecipientResponse struct {
+	UserID            string            `json:"user_id"`
+	Addresses         map[string]string `json:"addresses"`
+	PreferredChannels []string          `json:"preferred_channels"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+func RecipientToDomain(req SaveRecipientRequest) *domain.SaveRecipient {
+	rcpt := &domain.SaveRecipient{
+		Addresses:         make(map[domain.NotificationChannel]string, len(req.Addresses)),
+		PreferredChannels: make([]domain.NotificationChannel, 0, len(req.PreferredChannels)),
+	}
+	for ch, addr := range req.Addresses {
+		rcpt.Addresses[domain.NotificationChannel(ch)] = addr
+	}
+	for _, ch := range req.PreferredChannels {
+		rcpt.PreferredChannels = append(rcpt.PreferredChannels, domain.NotificationChannel(ch))
+	}
+	return rcpt
+}
+
+func FromDomainRecipient(r *domain.Recipient) RecipientResponse {
+	resp := RecipientResponse{
+		UserID:            r.UserID,
+		Addresses:         make(map[string]string, len(r.Addresses)),
+		PreferredChannels: make([]string, 0, len(r.PreferredChannels)),
+		CreatedAt:         r.CreatedAt,
+		UpdatedAt:         r.UpdatedAt,
+	}
+	for ch, addr := range r.Addresses {
+		resp.Addresses[string(ch)] = addr
+	}
+	for _, ch := range r.PreferredChannels {
+		resp.PreferredChannels = append(resp.PreferredChannels, string(ch))
+	}
+	return resp
+}
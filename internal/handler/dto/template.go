@@ -0,0 +1,57 @@
+package dto
+
+import (
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+type SaveTemplateRequest struct {
+	Name          string            `json:"name" validate:"required,max=100"`
+	TextBody      string            `json:"text_body" validate:"required"`
+	HTMLBody      string            `json:"html_body,omitempty"`
+	ChannelBodies map[string]string `json:"channel_bodies,omitempty" validate:"omitempty,dive,keys,channel,endkeys,required"`
+}
+
+type TemplateResponse struct {
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	TextBody      string            `json:"text_body"`
+	HTMLBody      string            `json:"html_body,omitempty"`
+	ChannelBodies map[string]string `json:"channel_bodies,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+func TemplateToDomain(req SaveTemplateRequest) *domain.SaveTemplate {
+	tmpl := &domain.SaveTemplate{
+		Name:     req.Name,
+		TextBody: req.TextBody,
+		HTMLBody: req.HTMLBody,
+	}
+	if len(req.ChannelBodies) > 0 {
+		tmpl.ChannelBodies = make(map[domain.NotificationChannel]string, len(req.ChannelBodies))
+		for ch, body := range req.ChannelBodies {
+			tmpl.ChannelBodies[domain.NotificationChannel(ch)] = body
+		}
+	}
+	return tmpl
+}
+
+func FromDomainTemplate(t *domain.Template) TemplateResponse {
+	resp := TemplateResponse{
+		ID:        t.ID,
+		Name:      t.Name,
+		TextBody:  t.TextBody,
+		HTMLBody:  t.HTMLBody,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+	}
+	if len(t.ChannelBodies) > 0 {
+		resp.ChannelBodies = make(map[string]string, len(t.ChannelBodies))
+		for ch, body := range t.ChannelBodies {
+			resp.ChannelBodies[string(ch)] = body
+		}
+	}
+	return resp
+}
@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -13,20 +16,45 @@ import (
 	"github.com/wb-go/wbf/zlog"
 )
 
+const (
+	maxIdempotencyKeyLen = 255
+	maxClientIDLen       = 100
+	maxBatchSize         = 1000
+	// maxBatchBodySize bounds the batch request body before it is decoded.
+	maxBatchBodySize = 32 << 20
+)
+
 type Handler struct {
-	service  NotificationService
-	validate *validator.Validate
+	service     NotificationService
+	templates   TemplateService
+	channels    ChannelRegistry
+	recipients  RecipientService
+	preferences PreferenceService
+	validate    *validator.Validate
 }
 
-func NewHandler(service NotificationService) *Handler {
+func NewHandler(
+	service NotificationService,
+	templates TemplateService,
+	channels ChannelRegistry,
+	recipients RecipientService,
+	preferences PreferenceService,
+) *Handler {
 	validate := validator.New()
 	validate.RegisterValidation("datetime", func(fl validator.FieldLevel) bool {
 		_, err := time.Parse(time.RFC3339, fl.Field().String())
 		return err == nil
 	})
+	validate.RegisterValidation("channel", func(fl validator.FieldLevel) bool {
+		return channels.Supports(domain.NotificationChannel(fl.Field().String()))
+	})
 	return &Handler{
-		service:  service,
-		validate: validate,
+		service:     service,
+		templates:   templates,
+		channels:    channels,
+		recipients:  recipients,
+		preferences: preferences,
+		validate:    validate,
 	}
 }
 
@@ -37,53 +65,291 @@ func (h *Handler) CreateNotification(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer r.Body.Close()
-	if err := h.validate.Struct(req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLen {
+		http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
 		return
 	}
-	notification, err := dto.ToDomain(req)
+	clientID, ok := clientIDFromRequest(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	directory, err := h.recipients.LoadRecipients(ctx, []string{req.UserID})
 	if err != nil {
+		zlog.Logger.Error().Err(err).Str("user_id", req.UserID).Msg("Failed to load recipient")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	notification, err := h.validateCreate(req, clientID, directory)
+	if err != nil {
+		if isValidationError(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		zlog.Logger.Error().Err(err).Str("user_id", req.UserID).Msg("Failed to validate notification")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	notification.IdempotencyKey = idempotencyKey
+	result, created, err := h.service.CreateNotification(ctx, notification)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrSendAtInPast),
+			errors.Is(err, domain.ErrInvalidRecurrence),
+			errors.Is(err, domain.ErrNoOccurrences),
+			errors.Is(err, domain.ErrMessageOrTemplateOnly),
+			errors.Is(err, domain.ErrTemplateNotFound),
+			errors.Is(err, domain.ErrTemplateParams):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, domain.ErrIdempotencyConflict), errors.Is(err, domain.ErrExternalIDExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			zlog.Logger.Error().Err(err).Msg("Failed to create notification")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	resp := dto.FromDomain(result)
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CreateNotifications creates up to maxBatchSize notifications in one request.
+// Every item is validated on its own and the response reports the created ID
+// or the validation error for each of them.
+func (h *Handler) CreateNotifications(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var req dto.BatchCreateRequest
+	body := http.MaxBytesReader(w, r.Body, maxBatchBodySize)
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("request body must not exceed %d bytes", maxBatchBodySize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if len(req.Notifications) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("batch must not exceed %d notifications", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+	clientID, ok := clientIDFromRequest(w, r)
+	if !ok {
+		return
+	}
 	ctx := r.Context()
-	result, err := h.service.CreateNotification(ctx, notification)
+	userIDs := make([]string, len(req.Notifications))
+	for i, item := range req.Notifications {
+		userIDs[i] = item.UserID
+	}
+	directory, err := h.recipients.LoadRecipients(ctx, userIDs)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Msg("Failed to create notification")
+		zlog.Logger.Error().Err(err).Msg("Failed to load batch recipients")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	resp := dto.FromDomain(result)
+	resp := dto.BatchCreateResponse{Results: make([]dto.BatchItemResponse, len(req.Notifications))}
+	items := make([]*domain.CreateNotification, 0, len(req.Notifications))
+	indexes := make([]int, 0, len(req.Notifications))
+	for i, item := range req.Notifications {
+		resp.Results[i].Index = i
+		notification, err := h.validateCreate(item, clientID, directory)
+		if err != nil {
+			if !isValidationError(err) {
+				zlog.Logger.Error().Err(err).Int("index", i).Msg("Failed to validate batch item")
+			}
+			resp.Results[i].Error = err.Error()
+			continue
+		}
+		items = append(items, notification)
+		indexes = append(indexes, i)
+	}
+	results, err := h.service.CreateNotifications(ctx, items)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("Failed to create notification batch")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for j, res := range results {
+		item := &resp.Results[indexes[j]]
+		if res.Err != nil {
+			item.Error = res.Err.Error()
+			continue
+		}
+		item.ID = res.Notification.ID
+	}
+	for _, item := range resp.Results {
+		if item.Error != "" {
+			resp.Failed++
+		} else {
+			resp.Created++
+		}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(resp)
 }
 
-func (h *Handler) GetNotificationStatus(w http.ResponseWriter, r *http.Request) {
+// validateCreate runs the request-level checks shared by single and batch
+// creation and returns the notification ready for the service. directory
+// holds the recipients loaded for the request, see LoadRecipients.
+func (h *Handler) validateCreate(
+	req dto.CreateNotificationRequest,
+	clientID string,
+	directory map[string]*domain.Recipient,
+) (*domain.CreateNotification, error) {
+	if err := h.validate.Struct(req); err != nil {
+		return nil, err
+	}
+	notification, err := dto.ToDomain(req)
+	if err != nil {
+		return nil, err
+	}
+	notification.ClientID = clientID
+	// Taken before the channel is filled in from the recipient directory, so
+	// that a replay stays a replay when the directory changes.
+	notification.RequestHash = dto.Fingerprint(req, clientID)
+	if err := h.channels.ValidateOptions(notification.ChannelOptions); err != nil {
+		return nil, err
+	}
+	chain := notification.Channels
+	if len(chain) == 0 {
+		chain = []domain.NotificationChannel{notification.Channel}
+	}
+	notification.Channel, err = h.resolveChannels(directory[notification.UserID], notification.UserID, chain)
+	if err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+// clientIDFromRequest reads the optional X-Client-ID header that scopes
+// external IDs. It writes the error response itself.
+func clientIDFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	clientID := r.Header.Get("X-Client-ID")
+	if len(clientID) > maxClientIDLen {
+		http.Error(w, "X-Client-ID is too long", http.StatusBadRequest)
+		return "", false
+	}
+	return clientID, true
+}
+
+// isValidationError reports whether err from validateCreate is the client's
+// fault rather than an internal failure.
+func isValidationError(err error) bool {
+	var validationErrs validator.ValidationErrors
+	var parseErr *time.ParseError
+	return errors.As(err, &validationErrs) ||
+		errors.As(err, &parseErr) ||
+		errors.Is(err, domain.ErrNoAddress) ||
+		errors.Is(err, domain.ErrChannelRequired) ||
+		errors.Is(err, domain.ErrUnknownChannel) ||
+		errors.Is(err, domain.ErrInvalidRecipient) ||
+		errors.Is(err, domain.ErrInvalidOptions)
+}
+
+// resolveChannels checks that every channel of the fallback chain can deliver
+// to the user and returns the first channel, taken from the recipient
+// directory when it is empty.
+func (h *Handler) resolveChannels(
+	rcpt *domain.Recipient,
+	userID string,
+	chain []domain.NotificationChannel,
+) (domain.NotificationChannel, error) {
+	var first domain.NotificationChannel
+	for i, ch := range chain {
+		channel, address, err := h.recipients.ResolveChannel(rcpt, userID, ch)
+		if err != nil {
+			return "", err
+		}
+		if i == 0 {
+			first = channel
+		}
+		if err := h.channels.ValidateRecipient(channel, address); err != nil {
+			return "", err
+		}
+	}
+	return first, nil
+}
+
+// validateUpdate applies the create-time channel checks to a channel change.
+// Changing only channel of a notification with a fallback chain is rejected,
+// since it would silently drop the rest of the chain.
+func (h *Handler) validateUpdate(ctx context.Context, id string, req dto.UpdateNotificationRequest, upd *domain.UpdateNotification) error {
+	if upd.Channels == nil {
+		return nil
+	}
+	existing, err := h.service.GetNotification(ctx, id)
+	if err != nil {
+		return err
+	}
+	if req.Channel != nil && len(existing.Channels) > 1 {
+		return domain.ErrChainUpdate
+	}
+	directory, err := h.recipients.LoadRecipients(ctx, []string{existing.UserID})
+	if err != nil {
+		return err
+	}
+	_, err = h.resolveChannels(directory[existing.UserID], existing.UserID, upd.Channels)
+	return err
+}
+
+func (h *Handler) GetNotification(w http.ResponseWriter, r *http.Request) {
 	id := strings.TrimPrefix(r.URL.Path, "/api/v1/notify/")
 	if id == "" {
 		http.Error(w, "ID is required", http.StatusBadRequest)
 		return
 	}
 	ctx := r.Context()
-	status, err := h.service.GetNotificationStatus(ctx, id)
+	notification, err := h.service.GetNotification(ctx, id)
 	if err != nil {
 		if err == domain.ErrNotFound {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		zlog.Logger.Error().Err(err).Str("id", id).Msg("Failed to get notification status")
+		zlog.Logger.Error().Err(err).Str("id", id).Msg("Failed to get notification")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	resp := dto.StatusResponse{
-		ID:     id,
-		Status: string(status),
+	attempts, err := h.service.ListAttempts(ctx, id)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("id", id).Msg("Failed to list delivery attempts")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	resp := dto.FromDomain(notification)
+	resp.Attempts = dto.FromDomainAttempts(attempts)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
+func (h *Handler) ListAttempts(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ctx := r.Context()
+	attempts, err := h.service.ListAttempts(ctx, id)
+	if err != nil {
+		if err == domain.ErrNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		zlog.Logger.Error().Err(err).Str("id", id).Msg("Failed to list delivery attempts")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.FromDomainAttempts(attempts))
+}
+
 func (h *Handler) CancelNotification(w http.ResponseWriter, r *http.Request) {
 	id := strings.TrimPrefix(r.URL.Path, "/api/v1/notify/")
 	if id == "" {
@@ -104,18 +370,114 @@ func (h *Handler) CancelNotification(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "notification cancelled successfully"})
 }
 
+func (h *Handler) CancelNotifications(w http.ResponseWriter, r *http.Request) {
+	var req dto.CancelNotificationsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := h.validate.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	cancelled, err := h.service.CancelNotifications(ctx, dto.CancelToDomain(req))
+	if err != nil {
+		if errors.Is(err, domain.ErrEmptyFilter) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		zlog.Logger.Error().Err(err).Msg("Failed to cancel notifications")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.CancelNotificationsResponse{Cancelled: cancelled})
+}
+
+func (h *Handler) UpdateNotification(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/notify/")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+	var req dto.UpdateNotificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := h.validate.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	upd, err := dto.UpdateToDomain(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	if err := h.validateUpdate(ctx, id, req, upd); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, domain.ErrChainUpdate), isValidationError(err):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			zlog.Logger.Error().Err(err).Str("id", id).Msg("Failed to validate notification update")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	result, err := h.service.UpdateNotification(ctx, id, upd)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, domain.ErrCannotUpdate):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, domain.ErrSendAtInPast),
+			errors.Is(err, domain.ErrNothingToUpdate),
+			errors.Is(err, domain.ErrTemplateMessage):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			zlog.Logger.Error().Err(err).Str("id", id).Msg("Failed to update notification")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.FromDomain(result))
+}
+
 func (h *Handler) ListNotifications(w http.ResponseWriter, r *http.Request) {
+	query, err := dto.ParseListQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.validate.Struct(query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter, err := dto.ListQueryToDomain(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	ctx := r.Context()
-	notifications, err := h.service.ListNotifications(ctx)
+	page, err := h.service.ListNotifications(ctx, filter)
 	if err != nil {
 		zlog.Logger.Error().Err(err).Msg("Failed to list notifications")
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	var resp []dto.NotificationResponse
-	for _, n := range notifications {
-		resp = append(resp, dto.FromDomain(n))
-	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(dto.FromDomainPage(page))
+}
+
+func (h *Handler) ListChannels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.FromDomainChannels(h.channels.Channels()))
 }
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"delayed-notifier/internal/domain"
+	"delayed-notifier/internal/handler/dto"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+func (h *Handler) SavePreferences(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	var req dto.SavePreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := h.validate.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pref, err := dto.PreferencesToDomain(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	saved, err := h.preferences.SavePreferences(ctx, userID, pref)
+	if err != nil {
+		h.writePreferenceError(w, err, "Failed to save preferences")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.FromDomainPreferences(saved))
+}
+
+func (h *Handler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	ctx := r.Context()
+	pref, err := h.preferences.GetPreferences(ctx, userID)
+	if err != nil {
+		h.writePreferenceError(w, err, "Failed to get preferences")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.FromDomainPreferences(pref))
+}
+
+func (h *Handler) DeletePreferences(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	ctx := r.Context()
+	if err := h.preferences.DeletePreferences(ctx, userID); err != nil {
+		h.writePreferenceError(w, err, "Failed to delete preferences")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "preferences deleted successfully"})
+}
+
+func (h *Handler) writePreferenceError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, domain.ErrPreferencesNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, domain.ErrInvalidPreferences):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		zlog.Logger.Error().Err(err).Msg(msg)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
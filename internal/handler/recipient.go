@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"delayed-notifier/internal/domain"
+	"delayed-notifier/internal/handler/dto"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+func (h *Handler) SaveRecipient(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	var req dto.SaveRecipientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := h.validate.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	rcpt, err := h.recipients.SaveRecipient(ctx, userID, dto.RecipientToDomain(req))
+	if err != nil {
+		h.writeRecipientError(w, err, "Failed to save recipient")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.FromDomainRecipient(rcpt))
+}
+
+func (h *Handler) GetRecipient(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	ctx := r.Context()
+	rcpt, err := h.recipients.GetRecipient(ctx, userID)
+	if err != nil {
+		h.writeRecipientError(w, err, "Failed to get recipient")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.FromDomainRecipient(rcpt))
+}
+
+func (h *Handler) DeleteRecipient(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user_id")
+	ctx := r.Context()
+	if err := h.recipients.DeleteRecipient(ctx, userID); err != nil {
+		h.writeRecipientError(w, err, "Failed to delete recipient")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "recipient deleted successfully"})
+}
+
+func (h *Handler) writeRecipientError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, domain.ErrRecipientNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, domain.ErrNoAddress),
+		errors.Is(err, domain.ErrUnknownChannel),
+		errors.Is(err, domain.ErrInvalidRecipient):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		zlog.Logger.Error().Err(err).Msg(msg)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
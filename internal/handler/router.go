@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"expvar"
 	"net/http"
 	"path/filepath"
 )
@@ -9,12 +10,31 @@ func SetupRouter(h *Handler) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("POST /api/v1/notify", h.CreateNotification)
-	mux.HandleFunc("GET /api/v1/notify/", h.GetNotificationStatus)
+	mux.HandleFunc("POST /api/v1/notify/batch", h.CreateNotifications)
+	mux.HandleFunc("POST /api/v1/notify/cancel", h.CancelNotifications)
+	mux.HandleFunc("GET /api/v1/notify/", h.GetNotification)
+	mux.HandleFunc("GET /api/v1/notify/{id}/attempts", h.ListAttempts)
 	mux.HandleFunc("DELETE /api/v1/notify/", h.CancelNotification)
+	mux.HandleFunc("PATCH /api/v1/notify/", h.UpdateNotification)
 	mux.HandleFunc("GET /api/v1/notifications", h.ListNotifications)
+	mux.HandleFunc("GET /api/v1/channels", h.ListChannels)
+
+	mux.HandleFunc("POST /api/v1/templates", h.CreateTemplate)
+	mux.HandleFunc("GET /api/v1/templates", h.ListTemplates)
+	mux.HandleFunc("GET /api/v1/templates/", h.GetTemplate)
+	mux.HandleFunc("PUT /api/v1/templates/", h.UpdateTemplate)
+	mux.HandleFunc("DELETE /api/v1/templates/", h.DeleteTemplate)
+
+	mux.HandleFunc("PUT /api/v1/recipients/{user_id}", h.SaveRecipient)
+	mux.HandleFunc("GET /api/v1/recipients/{user_id}", h.GetRecipient)
+	mux.HandleFunc("DELETE /api/v1/recipients/{user_id}", h.DeleteRecipient)
+
+	mux.HandleFunc("PUT /api/v1/preferences/{user_id}", h.SavePreferences)
+	mux.HandleFunc("GET /api/v1/preferences/{user_id}", h.GetPreferences)
+	mux.HandleFunc("DELETE /api/v1/preferences/{user_id}", h.DeletePreferences)
 
 	staticDir := "./static"
-	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.Dir(staticDir))))
 
 	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != "/" {
@@ -26,3 +46,11 @@ func SetupRouter(h *Handler) *http.ServeMux {
 
 	return mux
 }
+
+// SetupMetricsRouter serves expvar metrics. It is mounted on the internal
+// metrics listener, never on the public API.
+func SetupMetricsRouter() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	return mux
+}
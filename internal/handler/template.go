@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"delayed-notifier/internal/domain"
+	"delayed-notifier/internal/handler/dto"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+func (h *Handler) CreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req dto.SaveTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := h.validate.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	tmpl, err := h.templates.CreateTemplate(ctx, dto.TemplateToDomain(req))
+	if err != nil {
+		h.writeTemplateError(w, err, "Failed to create template")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.FromDomainTemplate(tmpl))
+}
+
+func (h *Handler) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/templates/")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	tmpl, err := h.templates.GetTemplate(ctx, id)
+	if err != nil {
+		h.writeTemplateError(w, err, "Failed to get template")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.FromDomainTemplate(tmpl))
+}
+
+func (h *Handler) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	templates, err := h.templates.ListTemplates(ctx)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("Failed to list templates")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp := make([]dto.TemplateResponse, 0, len(templates))
+	for _, t := range templates {
+		resp = append(resp, dto.FromDomainTemplate(t))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (h *Handler) UpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/templates/")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+	var req dto.SaveTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+	if err := h.validate.Struct(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	tmpl, err := h.templates.UpdateTemplate(ctx, id, dto.TemplateToDomain(req))
+	if err != nil {
+		h.writeTemplateError(w, err, "Failed to update template")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.FromDomainTemplate(tmpl))
+}
+
+func (h *Handler) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/v1/templates/")
+	if id == "" {
+		http.Error(w, "ID is required", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	if err := h.templates.DeleteTemplate(ctx, id); err != nil {
+		h.writeTemplateError(w, err, "Failed to delete template")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "template deleted successfully"})
+}
+
+func (h *Handler) writeTemplateError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, domain.ErrTemplateNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, domain.ErrTemplateExists), errors.Is(err, domain.ErrTemplateInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, domain.ErrInvalidTemplate):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		zlog.Logger.Error().Err(err).Msg(msg)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
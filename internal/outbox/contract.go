@@ -0,0 +1,16 @@
+package outbox
+
+import (
+	"context"
+
+	"delayed-notifier/internal/broker"
+	"delayed-notifier/internal/domain"
+)
+
+type Repository interface {
+	ProcessPending(ctx context.Context, limit int, publish func(entries []*domain.OutboxEntry) []error) (int, error)
+}
+
+type MessageBroker interface {
+	PublishDelayedBatch(ctx context.Context, msgs []broker.DelayedMessage) []error
+}
@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"delayed-notifier/internal/broker"
+	"delayed-notifier/internal/domain"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+type Config struct {
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+type Relay struct {
+	repo   Repository
+	broker MessageBroker
+	cfg    Config
+}
+
+func NewRelay(repo Repository, broker MessageBroker, cfg Config) *Relay {
+	return &Relay{
+		repo:   repo,
+		broker: broker,
+		cfg:    cfg,
+	}
+}
+
+func (r *Relay) Run(ctx context.Context) error {
+	zlog.Logger.Info().
+		Dur("poll_interval", r.cfg.PollInterval).
+		Int("batch_size", r.cfg.BatchSize).
+		Msg("Starting outbox relay")
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			zlog.Logger.Info().Msg("Outbox relay stopped")
+			return ctx.Err()
+		case <-ticker.C:
+			r.drain(ctx)
+		}
+	}
+}
+
+// drain keeps relaying batches until the outbox has no more ready entries or a
+// batch makes no progress, so bursts are not throttled by the poll interval.
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		processed, err := r.repo.ProcessPending(ctx, r.cfg.BatchSize, func(entries []*domain.OutboxEntry) []error {
+			return r.publish(ctx, entries)
+		})
+		if err != nil {
+			zlog.Logger.Error().Err(err).Msg("Failed to relay outbox entries")
+			return
+		}
+		if processed > 0 {
+			zlog.Logger.Info().Int("published", processed).Msg("Outbox entries relayed")
+		}
+		if processed < r.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// publish sends the whole batch before waiting for the broker confirms.
+func (r *Relay) publish(ctx context.Context, entries []*domain.OutboxEntry) []error {
+	msgs := make([]broker.DelayedMessage, len(entries))
+	for i, entry := range entries {
+		msgs[i] = broker.DelayedMessage{
+			NotificationID: entry.NotificationID,
+			Version:        entry.Version,
+			Delay:          max(time.Until(entry.PublishAt), 0),
+		}
+	}
+	errs := r.broker.PublishDelayedBatch(ctx, msgs)
+	for i, err := range errs {
+		if err != nil {
+			zlog.Logger.Warn().Err(err).
+				Int64("outbox_id", entries[i].ID).
+				Str("id", entries[i].NotificationID).
+				Msg("Failed to publish outbox entry")
+		}
+	}
+	return errs
+}
@@ -3,12 +3,20 @@ package cache
 import (
 	"context"
 	"delayed-notifier/internal/domain"
-	"time"
 )
 
+// Cache keeps notifications ordered by domain.Notification.Revision: a write
+// carrying an older revision than the cached one is ignored, so a stale read
+// from a replica cannot replace the state written by the repository.
 type Cache interface {
-	Set(ctx context.Context, id string, notif *domain.Notification, ttl time.Duration) error
+	// Get reports found = false on a miss. A nil notification with found = true
+	// means the id is cached as missing.
+	Get(ctx context.Context, id string) (notif *domain.Notification, found bool, err error)
+	Set(ctx context.Context, notif *domain.Notification) error
+	SetMany(ctx context.Context, notifs []*domain.Notification) error
+	// SetMissing caches the id as not found. Any stored notification wins over
+	// the marker.
+	SetMissing(ctx context.Context, id string) error
 	Del(ctx context.Context, id string) error
-	Get(ctx context.Context, id string) (*domain.Notification, error)
 	Close() error
 }
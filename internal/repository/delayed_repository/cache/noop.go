@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"delayed-notifier/internal/domain"
+)
+
+// NoopCache is used when caching is disabled; every read is a miss.
+type NoopCache struct{}
+
+func NewNoopCache() *NoopCache {
+	return &NoopCache{}
+}
+
+func (NoopCache) Get(ctx context.Context, id string) (*domain.Notification, bool, error) {
+	return nil, false, nil
+}
+
+func (NoopCache) Set(ctx context.Context, notif *domain.Notification) error {
+	return nil
+}
+
+func (NoopCache) SetMany(ctx context.Context, notifs []*domain.Notification) error {
+	return nil
+}
+
+func (NoopCache) SetMissing(ctx context.Context, id string) error {
+	return nil
+}
+
+func (NoopCache) Del(ctx context.Context, id string) error {
+	return nil
+}
+
+func (NoopCache) Close() error {
+	return nil
+}
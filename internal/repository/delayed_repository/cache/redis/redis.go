@@ -9,56 +9,166 @@ import (
 	"delayed-notifier/internal/config"
 	"delayed-notifier/internal/domain"
 
+	"github.com/go-redis/redis/v8"
 	wbfredis "github.com/wb-go/wbf/redis"
 	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
 )
 
+const (
+	keyPrefix = "notification:"
+	// legacyKeyPattern matches the plain string entries written before the
+	// versioned cache. They have no TTL and are no longer read.
+	legacyKeyPattern = "notif:*"
+	legacyScanCount  = 1000
+)
+
+// setScript stores the entry as a hash of its revision and payload unless the
+// cached revision is newer. An equal revision is rewritten to refresh the TTL.
+var setScript = redis.NewScript(`
+local current = tonumber(redis.call('HGET', KEYS[1], 'rev'))
+if current and current > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'rev', ARGV[1], 'data', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
 type RedisCache struct {
-	client  *wbfredis.Client
-	retries retry.Strategy
+	client      *wbfredis.Client
+	retries     retry.Strategy
+	ttl         time.Duration
+	negativeTTL time.Duration
 }
 
 func NewRedisCache(cfg *config.Config, retries retry.Strategy) *RedisCache {
 	client := wbfredis.New(cfg.RedisAddr(), cfg.Redis.Pass, cfg.Redis.DB)
 	return &RedisCache{
-		client:  client,
-		retries: retries,
+		client:      client,
+		retries:     retries,
+		ttl:         time.Duration(cfg.Cache.TTLHours) * time.Hour,
+		negativeTTL: cfg.Cache.NegativeTTL,
 	}
 }
 
-func (r *RedisCache) Get(ctx context.Context, id string) (*domain.Notification, error) {
-	val, err := r.client.GetWithRetry(ctx, r.retries, "notif:"+id)
+func (r *RedisCache) Get(ctx context.Context, id string) (*domain.Notification, bool, error) {
+	var vals []any
+	err := retry.DoContext(ctx, r.retries, func() error {
+		var err error
+		vals, err = r.client.Client.HMGet(ctx, keyPrefix+id, "rev", "data").Result()
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get from redis: %w", err)
+		return nil, false, fmt.Errorf("failed to get from redis: %w", err)
 	}
-	if val == "" {
-		return nil, nil
+	if vals[0] == nil {
+		return nil, false, nil
+	}
+	data, _ := vals[1].(string)
+	if data == "" {
+		return nil, true, nil
 	}
 	var notif domain.Notification
-	if err := json.Unmarshal([]byte(val), &notif); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal notification: %w", err)
+	if err := json.Unmarshal([]byte(data), &notif); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal notification: %w", err)
 	}
-	return &notif, nil
+	return &notif, true, nil
 }
 
-func (r *RedisCache) Set(ctx context.Context, id string, notif *domain.Notification, ttl time.Duration) error {
+func (r *RedisCache) Set(ctx context.Context, notif *domain.Notification) error {
 	data, err := json.Marshal(notif)
 	if err != nil {
 		return fmt.Errorf("failed to marshal notification: %w", err)
 	}
-	if err := r.client.SetWithRetry(ctx, r.retries, "notif:"+id, string(data)); err != nil {
+	err = retry.DoContext(ctx, r.retries, func() error {
+		return setScript.Run(ctx, r.client.Client, []string{keyPrefix + notif.ID},
+			notif.Revision, data, r.ttl.Milliseconds()).Err()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set in redis: %w", err)
+	}
+	return nil
+}
+
+// SetMany writes all notifications in a single pipeline.
+func (r *RedisCache) SetMany(ctx context.Context, notifs []*domain.Notification) error {
+	if len(notifs) == 0 {
+		return nil
+	}
+	payloads := make([][]byte, 0, len(notifs))
+	for _, notif := range notifs {
+		data, err := json.Marshal(notif)
+		if err != nil {
+			return fmt.Errorf("failed to marshal notification: %w", err)
+		}
+		payloads = append(payloads, data)
+	}
+	err := retry.DoContext(ctx, r.retries, func() error {
+		// EVALSHA cannot fall back to EVAL inside a pipeline, so the script
+		// is sent in full.
+		_, err := r.client.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, notif := range notifs {
+				setScript.Eval(ctx, pipe, []string{keyPrefix + notif.ID},
+					notif.Revision, payloads[i], r.ttl.Milliseconds())
+			}
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set in redis: %w", err)
+	}
+	return nil
+}
+
+// SetMissing stores an empty payload with revision 0 for negativeTTL, so the
+// first real write replaces it.
+func (r *RedisCache) SetMissing(ctx context.Context, id string) error {
+	err := retry.DoContext(ctx, r.retries, func() error {
+		return setScript.Run(ctx, r.client.Client, []string{keyPrefix + id},
+			0, "", r.negativeTTL.Milliseconds()).Err()
+	})
+	if err != nil {
 		return fmt.Errorf("failed to set in redis: %w", err)
 	}
 	return nil
 }
 
 func (r *RedisCache) Del(ctx context.Context, id string) error {
-	if err := r.client.DelWithRetry(ctx, r.retries, "notif:"+id); err != nil {
+	if err := r.client.DelWithRetry(ctx, r.retries, keyPrefix+id); err != nil {
 		return fmt.Errorf("failed to delete from redis: %w", err)
 	}
 	return nil
 }
 
+// DropLegacyKeys deletes the entries left under the old key prefix. It scans
+// incrementally, so it can run next to live traffic.
+func (r *RedisCache) DropLegacyKeys(ctx context.Context) error {
+	var cursor uint64
+	dropped := 0
+	for {
+		keys, next, err := r.client.Client.Scan(ctx, cursor, legacyKeyPattern, legacyScanCount).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan legacy cache keys: %w", err)
+		}
+		if len(keys) > 0 {
+			if err := r.client.Client.Unlink(ctx, keys...).Err(); err != nil {
+				return fmt.Errorf("failed to delete legacy cache keys: %w", err)
+			}
+			dropped += len(keys)
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	if dropped > 0 {
+		zlog.Logger.Info().Int("count", dropped).Msg("Dropped legacy cache keys")
+	}
+	return nil
+}
+
 func (r *RedisCache) Close() error {
 	if err := r.client.Close(); err != nil {
 		return fmt.Errorf("failed to close redis client: %w", err)
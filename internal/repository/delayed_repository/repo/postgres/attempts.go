@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+func (r *NotificationRepository) RecordAttempt(ctx context.Context, attempt *domain.DeliveryAttempt) error {
+	err := r.db.Master.QueryRowContext(ctx,
+		`INSERT INTO notification_attempts (notification_id, channel, started_at, duration_ms, success, error, provider_code)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id`,
+		attempt.NotificationID, attempt.Channel, attempt.StartedAt, attempt.Duration.Milliseconds(),
+		attempt.Success, nullString(attempt.Error), nullString(attempt.ProviderCode),
+	).Scan(&attempt.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record delivery attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) ListAttempts(ctx context.Context, notificationID string) ([]*domain.DeliveryAttempt, error) {
+	rows, err := r.db.QueryWithRetry(ctx, r.retries,
+		`SELECT id, notification_id, channel, started_at, duration_ms, success, error, provider_code
+FROM notification_attempts
+WHERE notification_id = $1
+ORDER BY started_at, id`, notificationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delivery attempts: %w", err)
+	}
+	defer rows.Close()
+	var attempts []*domain.DeliveryAttempt
+	for rows.Next() {
+		var attempt domain.DeliveryAttempt
+		var durationMs int64
+		var errText, providerCode sql.NullString
+		if err := rows.Scan(
+			&attempt.ID, &attempt.NotificationID, &attempt.Channel, &attempt.StartedAt,
+			&durationMs, &attempt.Success, &errText, &providerCode,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery attempt: %w", err)
+		}
+		attempt.Duration = time.Duration(durationMs) * time.Millisecond
+		attempt.Error = errText.String
+		attempt.ProviderCode = providerCode.String
+		attempts = append(attempts, &attempt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating delivery attempt rows: %w", err)
+	}
+	return attempts, nil
+}
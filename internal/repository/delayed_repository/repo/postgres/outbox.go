@@ -0,0 +1,163 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"delayed-notifier/internal/domain"
+
+	"github.com/lib/pq"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
+)
+
+// OutboxBackoff spaces out the publish attempts of a failing entry: the delay
+// doubles from Delay up to MaxDelay, and after MaxAttempts the entry is
+// dead-lettered.
+type OutboxBackoff struct {
+	MaxAttempts int
+	Delay       time.Duration
+	MaxDelay    time.Duration
+}
+
+// next returns the time of the attempt after the given number of failed ones
+// and whether the entry should be given up instead.
+func (b OutboxBackoff) next(failed int, now time.Time) (time.Time, bool) {
+	if failed >= b.MaxAttempts {
+		return time.Time{}, true
+	}
+	delay := b.Delay
+	for i := 1; i < failed && delay < b.MaxDelay; i++ {
+		delay *= 2
+	}
+	return now.Add(min(delay, b.MaxDelay)), false
+}
+
+type OutboxRepository struct {
+	db      *dbpg.DB
+	retries retry.Strategy
+	backoff OutboxBackoff
+}
+
+func NewOutboxRepository(db *dbpg.DB, retries retry.Strategy, backoff OutboxBackoff) *OutboxRepository {
+	return &OutboxRepository{
+		db:      db,
+		retries: retries,
+		backoff: backoff,
+	}
+}
+
+// ProcessPending locks up to limit due entries, hands them to publish as one
+// batch and deletes the successful ones in the same transaction. publish
+// returns the error of each entry in order. An entry is only deleted after it
+// was published, so a crash between the two steps results in a repeated
+// publish rather than a lost one. A failed entry waits for its next attempt
+// per the backoff, so it does not hold back newer entries; once dead-lettered
+// it stays in the table with dead_at set, and its notification is left to the
+// recovery sweep.
+func (r *OutboxRepository) ProcessPending(ctx context.Context, limit int, publish func(entries []*domain.OutboxEntry) []error) (int, error) {
+	processed := 0
+	err := r.db.WithTxWithRetry(ctx, r.retries, func(tx *sql.Tx) error {
+		processed = 0
+		rows, err := tx.QueryContext(ctx,
+			`SELECT id, notification_id, version, publish_at, attempts, created_at
+FROM outbox
+WHERE dead_at IS NULL AND next_attempt_at <= $1
+ORDER BY next_attempt_at, id
+LIMIT $2
+FOR UPDATE SKIP LOCKED`, time.Now(), limit)
+		if err != nil {
+			return fmt.Errorf("failed to select outbox entries: %w", err)
+		}
+		var entries []*domain.OutboxEntry
+		for rows.Next() {
+			var entry domain.OutboxEntry
+			if err := rows.Scan(&entry.ID, &entry.NotificationID, &entry.Version, &entry.PublishAt, &entry.Attempts, &entry.CreatedAt); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan outbox entry: %w", err)
+			}
+			entries = append(entries, &entry)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating outbox rows: %w", err)
+		}
+
+		if len(entries) == 0 {
+			return nil
+		}
+
+		var published []int64
+		now := time.Now()
+		for i, pubErr := range publish(entries) {
+			entry := entries[i]
+			if pubErr == nil {
+				published = append(published, entry.ID)
+				continue
+			}
+			nextAttempt, dead := r.backoff.next(entry.Attempts+1, now)
+			var deadAt any
+			if dead {
+				deadAt = now
+				nextAttempt = now
+				zlog.Logger.Error().
+					Int64("outbox_id", entry.ID).
+					Str("id", entry.NotificationID).
+					Int("attempts", entry.Attempts+1).
+					Msg("Dead-lettering outbox entry")
+			}
+			_, err := tx.ExecContext(ctx,
+				`UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2, dead_at = $3 WHERE id = $4`,
+				pubErr.Error(), nextAttempt, deadAt, entry.ID)
+			if err != nil {
+				return fmt.Errorf("failed to record outbox error: %w", err)
+			}
+		}
+		if len(published) == 0 {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM outbox WHERE id = ANY($1)`, pq.Array(published))
+		if err != nil {
+			return fmt.Errorf("failed to delete outbox entries: %w", err)
+		}
+		processed = len(published)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return processed, nil
+}
+
+func insertOutboxEntry(ctx context.Context, tx *sql.Tx, notificationID string, version int, publishAt time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO outbox (notification_id, version, publish_at, created_at) VALUES ($1, $2, $3, $4)`,
+		notificationID, version, publishAt, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert outbox entry: %w", err)
+	}
+	return nil
+}
+
+func insertOutboxEntries(ctx context.Context, tx *sql.Tx, notifs []*domain.Notification) error {
+	now := time.Now()
+	rows := make([]string, 0, len(notifs))
+	args := make([]any, 0, len(notifs)*4)
+	for _, notif := range notifs {
+		rows = append(rows, placeholders(len(args), 4))
+		args = append(args, notif.ID, notif.Version, notif.SendAt, now)
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO outbox (notification_id, version, publish_at, created_at) VALUES `+strings.Join(rows, ", "),
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert outbox entries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutboxBackoffNext(t *testing.T) {
+	b := OutboxBackoff{MaxAttempts: 5, Delay: time.Second, MaxDelay: 5 * time.Second}
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		failed int
+		want   time.Duration
+		dead   bool
+	}{
+		{failed: 1, want: time.Second},
+		{failed: 2, want: 2 * time.Second},
+		{failed: 3, want: 4 * time.Second},
+		{failed: 4, want: 5 * time.Second},
+		{failed: 5, dead: true},
+	}
+	for _, tt := range tests {
+		next, dead := b.next(tt.failed, now)
+		if dead != tt.dead {
+			t.Fatalf("next(%d) dead = %v, want %v", tt.failed, dead, tt.dead)
+		}
+		if !dead && next.Sub(now) != tt.want {
+			t.Fatalf("next(%d) = +%v, want +%v", tt.failed, next.Sub(now), tt.want)
+		}
+	}
+}
@@ -3,100 +3,604 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"delayed-notifier/internal/domain"
 	"delayed-notifier/internal/repository/delayed_repository/cache"
 
+	"github.com/lib/pq"
 	"github.com/wb-go/wbf/dbpg"
 	"github.com/wb-go/wbf/retry"
+	"github.com/wb-go/wbf/zlog"
 )
 
+const notificationColumns = `id, user_id, channel, subject, message, send_at, status, retries, version, revision,
+idempotency_key, request_hash, series_id, template_id, template_params, channel_options, channels, category, suppression_reason,
+client_id, external_id, tags, metadata, created_at, updated_at`
+
+// notificationProjection reads notificationColumns without the email
+// attachments; ChannelOptions loads them for sending.
+var notificationProjection = strings.Replace(notificationColumns, "channel_options,",
+	`CASE WHEN jsonb_typeof(channel_options -> '`+string(domain.ChannelEmail)+`') = 'object'
+THEN channel_options #- '{`+string(domain.ChannelEmail)+`,`+domain.AttachmentsOption+`}'
+ELSE channel_options END AS channel_options,`, 1)
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanNotification(row rowScanner) (*domain.Notification, error) {
+	var notif domain.Notification
+	var subject, idempotencyKey, requestHash, seriesID, templateID, category, suppressionReason sql.NullString
+	var clientID, externalID sql.NullString
+	var templateParams, channelOptions, metadata []byte
+	var channels []string
+	err := row.Scan(
+		&notif.ID, &notif.UserID, &notif.Channel, &subject, &notif.Message, &notif.SendAt,
+		&notif.Status, &notif.Retries, &notif.Version, &notif.Revision, &idempotencyKey, &requestHash, &seriesID,
+		&templateID, &templateParams, &channelOptions, pq.Array(&channels), &category, &suppressionReason,
+		&clientID, &externalID, pq.Array(&notif.Tags), &metadata, &notif.CreatedAt, &notif.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	notif.Subject = subject.String
+	notif.IdempotencyKey = idempotencyKey.String
+	notif.RequestHash = requestHash.String
+	notif.SeriesID = seriesID.String
+	notif.TemplateID = templateID.String
+	notif.Category = category.String
+	notif.SuppressionReason = suppressionReason.String
+	notif.ClientID = clientID.String
+	notif.ExternalID = externalID.String
+	if templateParams != nil {
+		if err := json.Unmarshal(templateParams, &notif.TemplateParams); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal template params: %w", err)
+		}
+	}
+	if channelOptions != nil {
+		notif.ChannelOptions = json.RawMessage(channelOptions)
+	}
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &notif.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+	notif.Channels = fromChannelStrings(channels)
+	return &notif, nil
+}
+
+func scanNotifications(rows *sql.Rows) ([]*domain.Notification, error) {
+	var notifs []*domain.Notification
+	for rows.Next() {
+		notif, err := scanNotification(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		notifs = append(notifs, notif)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating notification rows: %w", err)
+	}
+	return notifs, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func channelStrings(channels []domain.NotificationChannel) []string {
+	strs := make([]string, 0, len(channels))
+	for _, ch := range channels {
+		strs = append(strs, string(ch))
+	}
+	return strs
+}
+
+func fromChannelStrings(strs []string) []domain.NotificationChannel {
+	var channels []domain.NotificationChannel
+	for _, s := range strs {
+		channels = append(channels, domain.NotificationChannel(s))
+	}
+	return channels
+}
+
+func nullJSON(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return []byte(raw)
+}
+
+func notificationArgs(notif *domain.Notification) ([]any, error) {
+	var templateParams, metadata []byte
+	if notif.TemplateParams != nil {
+		var err error
+		templateParams, err = json.Marshal(notif.TemplateParams)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal template params: %w", err)
+		}
+	}
+	if notif.Metadata != nil {
+		var err error
+		metadata, err = json.Marshal(notif.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+		}
+	}
+	tags := notif.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return []any{
+		notif.ID, notif.UserID, notif.Channel, nullString(notif.Subject), notif.Message, notif.SendAt,
+		notif.Status, notif.Retries, notif.Version, notif.Revision, nullString(notif.IdempotencyKey), nullString(notif.RequestHash),
+		nullString(notif.SeriesID), nullString(notif.TemplateID), templateParams, nullJSON(notif.ChannelOptions),
+		pq.Array(channelStrings(notif.Channels)), nullString(notif.Category), nullString(notif.SuppressionReason),
+		nullString(notif.ClientID), nullString(notif.ExternalID), pq.Array(tags), metadata,
+		notif.CreatedAt, notif.UpdatedAt,
+	}, nil
+}
+
+// placeholders returns "($offset+1, ..., $offset+n)".
+func placeholders(offset, n int) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for i := 1; i <= n; i++ {
+		if i > 1 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "$%d", offset+i)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// contextConds appends the conditions matching notifications that have all
+// the tags and the given value for every metadata key.
+func contextConds(conds []string, arg func(v any) string, tags []string, metadata map[string]string) []string {
+	if len(tags) > 0 {
+		conds = append(conds, "tags @> "+arg(pq.Array(tags)))
+	}
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		conds = append(conds, fmt.Sprintf("metadata->>%s = %s", arg(k), arg(metadata[k])))
+	}
+	return conds
+}
+
+// insertNotification writes the notification together with its outbox entry.
+// It reports false when the row was skipped because of a unique conflict on the
+// idempotency key or on the series occurrence.
+func insertNotification(ctx context.Context, tx *sql.Tx, notif *domain.Notification) (bool, error) {
+	args, err := notificationArgs(notif)
+	if err != nil {
+		return false, err
+	}
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO notifications (`+notificationColumns+`)
+VALUES `+placeholders(0, len(args))+`
+ON CONFLICT DO NOTHING`,
+		args...,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	return true, insertOutboxEntry(ctx, tx, notif.ID, notif.Version, notif.SendAt)
+}
+
+// permanentError aborts the transaction in withTx without another attempt.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// withTx runs fn in a master transaction using the repository retry strategy.
+// Errors wrapped in permanentError roll back and are returned as is.
+func (r *NotificationRepository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	var permanent *permanentError
+	err := retry.DoContext(ctx, r.retries, func() error {
+		err := r.db.WithTx(ctx, fn)
+		if errors.As(err, &permanent) {
+			return nil
+		}
+		return err
+	})
+	if permanent != nil {
+		return permanent.err
+	}
+	return err
+}
+
+// NotificationRepository writes every notification it changes through to the
+// cache, so readers never fall back to a replica that may lag behind the
+// change. Rows are read back from the master with RETURNING for that.
 type NotificationRepository struct {
 	db      *dbpg.DB
 	cache   cache.Cache
 	retries retry.Strategy
-	ttl     time.Duration
 }
 
 func NewNotificationRepository(
 	db *dbpg.DB,
 	cache cache.Cache,
 	retries retry.Strategy,
-	ttl time.Duration,
 ) *NotificationRepository {
 	r := &NotificationRepository{
 		db:      db,
 		cache:   cache,
 		retries: retries,
-		ttl:     ttl,
 	}
 
 	return r
 }
 
+// cacheNotifications writes the notifications to the cache. If that fails the
+// entries are dropped instead, so they are not served stale until they expire.
+func (r *NotificationRepository) cacheNotifications(ctx context.Context, notifs ...*domain.Notification) {
+	if len(notifs) == 0 {
+		return
+	}
+	cached := make([]*domain.Notification, len(notifs))
+	for i, notif := range notifs {
+		c := *notif
+		c.ChannelOptions = domain.WithoutAttachments(notif.ChannelOptions)
+		cached[i] = &c
+	}
+	err := r.cache.SetMany(ctx, cached)
+	if err == nil {
+		return
+	}
+	zlog.Logger.Warn().Err(err).Int("count", len(notifs)).Msg("Failed to update cached notifications")
+	for _, notif := range notifs {
+		if err := r.cache.Del(ctx, notif.ID); err != nil {
+			zlog.Logger.Error().Err(err).Str("id", notif.ID).Msg("Failed to drop cached notification")
+		}
+	}
+}
+
+// updateReturning runs an UPDATE of a single notification on the master and
+// caches the resulting row. query must not have a RETURNING clause. It
+// returns nil when no row matched.
+func (r *NotificationRepository) updateReturning(ctx context.Context, query string, args ...any) (*domain.Notification, error) {
+	var notif *domain.Notification
+	err := retry.DoContext(ctx, r.retries, func() error {
+		var err error
+		notif, err = scanNotification(r.db.Master.QueryRowContext(ctx, query+"\nRETURNING "+notificationProjection, args...))
+		if err == sql.ErrNoRows {
+			notif = nil
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if notif != nil {
+		r.cacheNotifications(ctx, notif)
+	}
+	return notif, nil
+}
+
 func (r *NotificationRepository) Create(ctx context.Context, notif *domain.Notification) error {
-	_, err := r.db.ExecWithRetry(ctx, r.retries,
-		`INSERT INTO notifications (id, user_id, channel, message, send_at, status, retries, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-		notif.ID, notif.UserID, notif.Channel, notif.Message, notif.SendAt,
-		notif.Status, notif.Retries, notif.CreatedAt, notif.UpdatedAt,
-	)
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		inserted, err := insertNotification(ctx, tx, notif)
+		if err != nil {
+			return err
+		}
+		if !inserted {
+			return &permanentError{err: domain.ErrIdempotencyKeyExists}
+		}
+		return nil
+	})
+	if errors.Is(err, domain.ErrIdempotencyKeyExists) {
+		return err
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create notification: %w", err)
 	}
-	r.cache.Set(ctx, notif.ID, notif, r.ttl)
+	r.cacheNotifications(ctx, notif)
 	return nil
 }
 
+// CreateBatch inserts the notifications and their outbox entries with one
+// multi-row INSERT each, in a single transaction. It reports for every
+// notification whether it was inserted; rows are skipped when their
+// external_id is already taken.
+func (r *NotificationRepository) CreateBatch(ctx context.Context, notifs []*domain.Notification) ([]bool, error) {
+	inserted := make([]bool, len(notifs))
+	if len(notifs) == 0 {
+		return inserted, nil
+	}
+	var created []*domain.Notification
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		created = nil
+		rows := make([]string, 0, len(notifs))
+		var args []any
+		for _, notif := range notifs {
+			notifArgs, err := notificationArgs(notif)
+			if err != nil {
+				return &permanentError{err: err}
+			}
+			rows = append(rows, placeholders(len(args), len(notifArgs)))
+			args = append(args, notifArgs...)
+		}
+		res, err := tx.QueryContext(ctx,
+			`INSERT INTO notifications (`+notificationColumns+`) VALUES `+strings.Join(rows, ", ")+`
+ON CONFLICT DO NOTHING
+RETURNING id`,
+			args...,
+		)
+		if err != nil {
+			return err
+		}
+		ids := make(map[string]bool, len(notifs))
+		for res.Next() {
+			var id string
+			if err := res.Scan(&id); err != nil {
+				res.Close()
+				return err
+			}
+			ids[id] = true
+		}
+		res.Close()
+		if err := res.Err(); err != nil {
+			return err
+		}
+		for i, notif := range notifs {
+			inserted[i] = ids[notif.ID]
+			if inserted[i] {
+				created = append(created, notif)
+			}
+		}
+		if len(created) == 0 {
+			return nil
+		}
+		return insertOutboxEntries(ctx, tx, created)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create notifications: %w", err)
+	}
+	r.cacheNotifications(ctx, created...)
+	return inserted, nil
+}
+
 func (r *NotificationRepository) Get(ctx context.Context, id string) (*domain.Notification, error) {
-	cached, err := r.cache.Get(ctx, id)
-	if err == nil && cached != nil {
+	cached, found, err := r.cache.Get(ctx, id)
+	if err == nil && found {
 		return cached, nil
 	}
 	row, err := r.db.QueryRowWithRetry(ctx, r.retries,
-		`SELECT id, user_id, channel, message, send_at, status, retries, created_at, updated_at
-FROM notifications WHERE id = $1`, id)
+		`SELECT `+notificationProjection+` FROM notifications WHERE id = $1`, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query notification: %w", err)
 	}
-	var notif domain.Notification
-	err = row.Scan(
-		&notif.ID, &notif.UserID, &notif.Channel, &notif.Message, &notif.SendAt,
-		&notif.Status, &notif.Retries, &notif.CreatedAt, &notif.UpdatedAt,
-	)
+	notif, err := scanNotification(row)
+	if err == sql.ErrNoRows {
+		// The replica may not have a just created row yet. Only a miss
+		// confirmed by the master is cached, otherwise the notification would
+		// read as missing for the whole negative TTL.
+		notif, err = scanNotification(r.db.Master.QueryRowContext(ctx,
+			`SELECT `+notificationProjection+` FROM notifications WHERE id = $1`, id))
+	}
 	if err == sql.ErrNoRows {
+		if err := r.cache.SetMissing(ctx, id); err != nil {
+			zlog.Logger.Warn().Err(err).Str("id", id).Msg("Failed to cache missing notification")
+		}
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan notification: %w", err)
 	}
-	r.cache.Set(ctx, id, &notif, r.ttl)
-	return &notif, nil
+	// The revision check keeps a lagging replica from replacing a newer entry
+	// written by a concurrent update.
+	if err := r.cache.Set(ctx, notif); err != nil {
+		zlog.Logger.Warn().Err(err).Str("id", id).Msg("Failed to cache notification")
+	}
+	return notif, nil
+}
+
+// GetByIdempotencyKey looks the key up among the notifications of clientID,
+// like external IDs. It always reads from the master so that a key inserted by
+// a concurrent request is visible immediately.
+func (r *NotificationRepository) GetByIdempotencyKey(ctx context.Context, clientID, key string) (*domain.Notification, error) {
+	notif, err := scanNotification(r.db.Master.QueryRowContext(ctx,
+		`SELECT `+notificationProjection+` FROM notifications
+WHERE COALESCE(client_id, '') = $1 AND idempotency_key = $2`, clientID, key))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification by idempotency key: %w", err)
+	}
+	return notif, nil
+}
+
+// ChannelOptions reads the full channel options of a notification from the
+// master, including the attachments left out of every other read.
+func (r *NotificationRepository) ChannelOptions(ctx context.Context, id string) (json.RawMessage, error) {
+	var options []byte
+	err := retry.DoContext(ctx, r.retries, func() error {
+		err := r.db.Master.QueryRowContext(ctx,
+			`SELECT channel_options FROM notifications WHERE id = $1`, id,
+		).Scan(&options)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query channel options: %w", err)
+	}
+	return options, nil
 }
 
 func (r *NotificationRepository) UpdateStatus(ctx context.Context, id string, status domain.NotificationStatus) error {
-	_, err := r.db.ExecWithRetry(ctx, r.retries,
+	_, err := r.updateReturning(ctx,
 		`UPDATE notifications SET status = $1, updated_at = $2 WHERE id = $3`,
 		status, time.Now(), id,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update status: %w", err)
 	}
-	r.cache.Del(ctx, id)
 	return nil
 }
 
+// Update changes a pending notification, bumps its version and enqueues a new
+// outbox entry for it. It returns nil when the notification is missing, is no
+// longer pending or is rendered from a template and the message is changed.
+func (r *NotificationRepository) Update(ctx context.Context, id string, upd *domain.UpdateNotification) (*domain.Notification, error) {
+	var channels any
+	if upd.Channels != nil {
+		channels = pq.Array(channelStrings(upd.Channels))
+	}
+	var notif *domain.Notification
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		notif, err = scanNotification(tx.QueryRowContext(ctx,
+			`UPDATE notifications SET
+	channel = COALESCE($1::text, channel),
+	channels = COALESCE($2::text[], channels),
+	message = COALESCE($3, message),
+	send_at = COALESCE($4, send_at),
+	version = version + 1,
+	updated_at = $5
+WHERE id = $6 AND status = $7 AND ($3::text IS NULL OR template_id IS NULL)
+RETURNING `+notificationProjection,
+			upd.Channel, channels, upd.Message, upd.SendAt, time.Now(), id, domain.StatusPending,
+		))
+		if err == sql.ErrNoRows {
+			notif = nil
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return insertOutboxEntry(ctx, tx, notif.ID, notif.Version, notif.SendAt)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update notification: %w", err)
+	}
+	if notif != nil {
+		r.cacheNotifications(ctx, notif)
+	}
+	return notif, nil
+}
+
+func (r *NotificationRepository) Claim(ctx context.Context, id string, version int) (*domain.Notification, error) {
+	notif, err := r.updateReturning(ctx,
+		`UPDATE notifications SET status = $1, claimed_at = $2, updated_at = $2
+WHERE id = $3 AND status = $4 AND send_at <= $2 AND ($5 = 0 OR version = $5)`,
+		domain.StatusProcessing, time.Now(), id, domain.StatusPending, version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim notification: %w", err)
+	}
+	return notif, nil
+}
+
+func (r *NotificationRepository) ReleaseExpiredClaims(ctx context.Context, before time.Time, limit int) ([]*domain.Notification, error) {
+	rows, err := r.db.Master.QueryContext(ctx,
+		`UPDATE notifications SET status = $1, updated_at = $2
+WHERE id IN (
+	SELECT id FROM notifications
+	WHERE status = $3 AND claimed_at < $4
+	ORDER BY claimed_at
+	LIMIT $5
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING `+notificationProjection,
+		domain.StatusPending, time.Now(), domain.StatusProcessing, before, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to release expired claims: %w", err)
+	}
+	defer rows.Close()
+	notifs, err := scanNotifications(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to release expired claims: %w", err)
+	}
+	r.cacheNotifications(ctx, notifs...)
+	return notifs, nil
+}
+
 func (r *NotificationRepository) IncrementRetry(ctx context.Context, id string) error {
-	_, err := r.db.ExecWithRetry(ctx, r.retries,
+	_, err := r.updateReturning(ctx,
 		`UPDATE notifications SET retries = retries + 1, updated_at = $1 WHERE id = $2`,
 		time.Now(), id,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to increment retry: %w", err)
 	}
-	r.cache.Del(ctx, id)
+	return nil
+}
+
+// AdvanceChannel switches the notification to the next channel of its fallback
+// chain and returns it to pending with a fresh retry budget.
+func (r *NotificationRepository) AdvanceChannel(ctx context.Context, id string, channel domain.NotificationChannel) error {
+	_, err := r.updateReturning(ctx,
+		`UPDATE notifications SET channel = $1, retries = 0, status = $2, updated_at = $3 WHERE id = $4`,
+		channel, domain.StatusPending, time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to advance channel: %w", err)
+	}
+	return nil
+}
+
+// Defer returns a claimed notification to pending with send_at moved to
+// sendAt, so neither the claim nor the recovery sweep picks it up earlier. A
+// series occurrence is not moved onto another occurrence of the same series;
+// domain.ErrOccurrenceExists is returned instead.
+func (r *NotificationRepository) Defer(ctx context.Context, id string, sendAt time.Time) error {
+	notif, err := r.updateReturning(ctx,
+		`UPDATE notifications SET status = $1, send_at = $2, updated_at = $3
+WHERE id = $4 AND NOT EXISTS (
+	SELECT 1 FROM notifications o
+	WHERE o.series_id = notifications.series_id AND o.send_at = $2 AND o.id <> $4
+)`,
+		domain.StatusPending, sendAt, time.Now(), id,
+	)
+	if isUniqueViolation(err) {
+		// A concurrent insert took the slot between the check and the update.
+		return domain.ErrOccurrenceExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to defer notification: %w", err)
+	}
+	if notif == nil {
+		return domain.ErrOccurrenceExists
+	}
+	return nil
+}
+
+func (r *NotificationRepository) SetSuppressionReason(ctx context.Context, id string, reason string) error {
+	_, err := r.updateReturning(ctx,
+		`UPDATE notifications SET suppression_reason = $1, updated_at = $2 WHERE id = $3`,
+		reason, time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set suppression reason: %w", err)
+	}
 	return nil
 }
 
@@ -111,59 +615,143 @@ func (r *NotificationRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-func (r *NotificationRepository) List(ctx context.Context) ([]*domain.Notification, error) {
-	rows, err := r.db.QueryWithRetry(ctx, r.retries,
-		`SELECT id, user_id, channel, message, send_at, status, retries, created_at, updated_at
-FROM notifications ORDER BY created_at DESC LIMIT 100`)
+// CancelMatching cancels every pending notification matching the filter in a
+// single statement. Series of the cancelled occurrences are stopped as well, as
+// with a single cancel.
+func (r *NotificationRepository) CancelMatching(ctx context.Context, filter domain.CancelFilter) (int, error) {
+	args := []any{domain.StatusCancelled, time.Now(), domain.StatusPending, domain.SeriesCancelled, domain.SeriesActive}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	conds := []string{"status = $3"}
+	if filter.UserID != "" {
+		conds = append(conds, "user_id = "+arg(filter.UserID))
+	}
+	if filter.Channel != "" {
+		conds = append(conds, "channel = "+arg(filter.Channel))
+	}
+	if len(filter.IDs) > 0 {
+		conds = append(conds, "id = ANY("+arg(pq.Array(filter.IDs))+")")
+	}
+	conds = contextConds(conds, arg, filter.Tags, filter.Metadata)
+	rows, err := r.db.Master.QueryContext(ctx,
+		`WITH cancelled AS (
+	UPDATE notifications SET status = $1, updated_at = $2
+	WHERE `+strings.Join(conds, " AND ")+`
+	RETURNING `+notificationProjection+`
+), stopped AS (
+	UPDATE notification_series SET status = $4, updated_at = $2
+	WHERE id IN (SELECT series_id FROM cancelled) AND status = $5
+)
+SELECT `+notificationColumns+` FROM cancelled`,
+		args...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel notifications: %w", err)
+	}
+	defer rows.Close()
+	cancelled, err := scanNotifications(rows)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cancel notifications: %w", err)
+	}
+	r.cacheNotifications(ctx, cancelled...)
+	return len(cancelled), nil
+}
+
+// List returns up to filter.Limit notifications ordered by (created_at, id)
+// descending, starting after filter.Cursor. One extra row is fetched to decide
+// whether another page exists.
+func (r *NotificationRepository) List(ctx context.Context, filter domain.ListFilter) (*domain.NotificationPage, error) {
+	var conds []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.Status != "" {
+		conds = append(conds, "status = "+arg(filter.Status))
+	}
+	if filter.Channel != "" {
+		conds = append(conds, "channel = "+arg(filter.Channel))
+	}
+	if filter.UserID != "" {
+		conds = append(conds, "user_id = "+arg(filter.UserID))
+	}
+	if filter.ClientID != "" {
+		conds = append(conds, "client_id = "+arg(filter.ClientID))
+	}
+	if filter.ExternalID != "" {
+		conds = append(conds, "external_id = "+arg(filter.ExternalID))
+	}
+	conds = contextConds(conds, arg, filter.Tags, filter.Metadata)
+	if filter.SendAtFrom != nil {
+		conds = append(conds, "send_at >= "+arg(*filter.SendAtFrom))
+	}
+	if filter.SendAtTo != nil {
+		conds = append(conds, "send_at < "+arg(*filter.SendAtTo))
+	}
+	if filter.CreatedFrom != nil {
+		conds = append(conds, "created_at >= "+arg(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		conds = append(conds, "created_at < "+arg(*filter.CreatedTo))
+	}
+	if filter.Cursor != nil {
+		conds = append(conds, fmt.Sprintf("(created_at, id) < (%s, %s)",
+			arg(filter.Cursor.CreatedAt), arg(filter.Cursor.ID)))
+	}
+	query := `SELECT ` + notificationProjection + ` FROM notifications`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT " + arg(filter.Limit+1)
+
+	rows, err := r.db.QueryWithRetry(ctx, r.retries, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list notifications: %w", err)
 	}
 	defer rows.Close()
-	var notifs []*domain.Notification
-	for rows.Next() {
-		var notif domain.Notification
-		err := rows.Scan(
-			&notif.ID, &notif.UserID, &notif.Channel, &notif.Message, &notif.SendAt,
-			&notif.Status, &notif.Retries, &notif.CreatedAt, &notif.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan notification in list: %w", err)
-		}
-		notifs = append(notifs, &notif)
+	notifs, err := scanNotifications(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifications: %w", err)
 	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows in list: %w", err)
+	page := &domain.NotificationPage{Notifications: notifs}
+	if len(notifs) > filter.Limit {
+		page.Notifications = notifs[:filter.Limit]
+		last := page.Notifications[filter.Limit-1]
+		page.NextCursor = &domain.ListCursor{CreatedAt: last.CreatedAt, ID: last.ID}
 	}
-	return notifs, nil
+	return page, nil
 }
 
-func (r *NotificationRepository) GetPendingNotifications(ctx context.Context) ([]*domain.Notification, error) {
-	rows, err := r.db.QueryWithRetry(ctx, r.retries,
-		`SELECT id, user_id, channel, message, send_at, status, retries, created_at, updated_at
-			FROM notifications
-			WHERE status = $1 AND send_at <= $2
-			ORDER BY send_at ASC
-			LIMIT 100`,
-		domain.StatusPending, time.Now(),
+// MarkOverdueRequeued stamps requeued_at on up to limit pending notifications
+// that were due and untouched before `before` and returns them for the sweep
+// to republish. Rows requeued after requeuedBefore are skipped, so a backlog
+// the workers have not reached yet is not published again on every tick.
+func (r *NotificationRepository) MarkOverdueRequeued(ctx context.Context, before, requeuedBefore time.Time, limit int) ([]*domain.Notification, error) {
+	rows, err := r.db.Master.QueryContext(ctx,
+		`UPDATE notifications SET requeued_at = $1
+WHERE id IN (
+	SELECT id FROM notifications
+	WHERE status = $2 AND send_at <= $3 AND updated_at <= $3
+		AND (requeued_at IS NULL OR requeued_at <= $4)
+	ORDER BY send_at
+	LIMIT $5
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING `+notificationProjection,
+		time.Now(), domain.StatusPending, before, requeuedBefore, limit,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get pending notifications: %w", err)
+		return nil, fmt.Errorf("failed to mark overdue notifications: %w", err)
 	}
 	defer rows.Close()
-	var notifs []*domain.Notification
-	for rows.Next() {
-		var notif domain.Notification
-		err := rows.Scan(
-			&notif.ID, &notif.UserID, &notif.Channel, &notif.Message, &notif.SendAt,
-			&notif.Status, &notif.Retries, &notif.CreatedAt, &notif.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan pending notification: %w", err)
-		}
-		notifs = append(notifs, &notif)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows in pending: %w", err)
+	notifs, err := scanNotifications(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark overdue notifications: %w", err)
 	}
+	// The revision trigger bumped every returned row.
+	r.cacheNotifications(ctx, notifs...)
 	return notifs, nil
 }
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"delayed-notifier/internal/domain"
+
+	"github.com/lib/pq"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+)
+
+const preferenceColumns = `user_id, timezone, quiet_start, quiet_end, opted_out_channels, opted_out_categories,
+created_at, updated_at`
+
+type PreferenceRepository struct {
+	db      *dbpg.DB
+	retries retry.Strategy
+}
+
+func NewPreferenceRepository(db *dbpg.DB, retries retry.Strategy) *PreferenceRepository {
+	return &PreferenceRepository{
+		db:      db,
+		retries: retries,
+	}
+}
+
+func scanPreferences(row rowScanner) (*domain.Preferences, error) {
+	var pref domain.Preferences
+	var quietStart, quietEnd sql.NullInt16
+	var channels, categories []string
+	err := row.Scan(&pref.UserID, &pref.Timezone, &quietStart, &quietEnd,
+		pq.Array(&channels), pq.Array(&categories), &pref.CreatedAt, &pref.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if quietStart.Valid && quietEnd.Valid {
+		pref.QuietHours = &domain.QuietHours{Start: int(quietStart.Int16), End: int(quietEnd.Int16)}
+	}
+	pref.OptedOutChannels = fromChannelStrings(channels)
+	pref.OptedOutCategories = categories
+	return &pref, nil
+}
+
+// Save inserts the preferences or replaces the existing ones, keeping their
+// created_at.
+func (r *PreferenceRepository) Save(ctx context.Context, pref *domain.Preferences) (*domain.Preferences, error) {
+	var quietStart, quietEnd sql.NullInt16
+	if pref.QuietHours != nil {
+		quietStart = sql.NullInt16{Int16: int16(pref.QuietHours.Start), Valid: true}
+		quietEnd = sql.NullInt16{Int16: int16(pref.QuietHours.End), Valid: true}
+	}
+	categories := pref.OptedOutCategories
+	if categories == nil {
+		categories = []string{}
+	}
+	saved, err := scanPreferences(r.db.Master.QueryRowContext(ctx,
+		`INSERT INTO user_preferences (`+preferenceColumns+`)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (user_id) DO UPDATE SET
+    timezone = EXCLUDED.timezone,
+    quiet_start = EXCLUDED.quiet_start,
+    quiet_end = EXCLUDED.quiet_end,
+    opted_out_channels = EXCLUDED.opted_out_channels,
+    opted_out_categories = EXCLUDED.opted_out_categories,
+    updated_at = EXCLUDED.updated_at
+RETURNING `+preferenceColumns,
+		pref.UserID, pref.Timezone, quietStart, quietEnd,
+		pq.Array(channelStrings(pref.OptedOutChannels)), pq.Array(categories), pref.CreatedAt, pref.UpdatedAt,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save preferences: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *PreferenceRepository) Get(ctx context.Context, userID string) (*domain.Preferences, error) {
+	row, err := r.db.QueryRowWithRetry(ctx, r.retries,
+		`SELECT `+preferenceColumns+` FROM user_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query preferences: %w", err)
+	}
+	pref, err := scanPreferences(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan preferences: %w", err)
+	}
+	return pref, nil
+}
+
+func (r *PreferenceRepository) Delete(ctx context.Context, userID string) error {
+	res, err := r.db.ExecWithRetry(ctx, r.retries, `DELETE FROM user_preferences WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrPreferencesNotFound
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"delayed-notifier/internal/domain"
+
+	"github.com/lib/pq"
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+)
+
+const recipientColumns = `user_id, addresses, preferred_channels, created_at, updated_at`
+
+type RecipientRepository struct {
+	db      *dbpg.DB
+	retries retry.Strategy
+}
+
+func NewRecipientRepository(db *dbpg.DB, retries retry.Strategy) *RecipientRepository {
+	return &RecipientRepository{
+		db:      db,
+		retries: retries,
+	}
+}
+
+func scanRecipient(row rowScanner) (*domain.Recipient, error) {
+	var rcpt domain.Recipient
+	var addresses []byte
+	var preferred []string
+	err := row.Scan(&rcpt.UserID, &addresses, pq.Array(&preferred), &rcpt.CreatedAt, &rcpt.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(addresses, &rcpt.Addresses); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal recipient addresses: %w", err)
+	}
+	rcpt.PreferredChannels = fromChannelStrings(preferred)
+	return &rcpt, nil
+}
+
+// Save inserts the recipient or replaces the addresses and preferences of an
+// existing one, keeping its created_at.
+func (r *RecipientRepository) Save(ctx context.Context, rcpt *domain.Recipient) (*domain.Recipient, error) {
+	addresses, err := json.Marshal(rcpt.Addresses)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal recipient addresses: %w", err)
+	}
+	saved, err := scanRecipient(r.db.Master.QueryRowContext(ctx,
+		`INSERT INTO recipients (`+recipientColumns+`)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (user_id) DO UPDATE SET
+    addresses = EXCLUDED.addresses,
+    preferred_channels = EXCLUDED.preferred_channels,
+    updated_at = EXCLUDED.updated_at
+RETURNING `+recipientColumns,
+		rcpt.UserID, addresses, pq.Array(channelStrings(rcpt.PreferredChannels)), rcpt.CreatedAt, rcpt.UpdatedAt,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save recipient: %w", err)
+	}
+	return saved, nil
+}
+
+func (r *RecipientRepository) Get(ctx context.Context, userID string) (*domain.Recipient, error) {
+	row, err := r.db.QueryRowWithRetry(ctx, r.retries,
+		`SELECT `+recipientColumns+` FROM recipients WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recipient: %w", err)
+	}
+	rcpt, err := scanRecipient(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan recipient: %w", err)
+	}
+	return rcpt, nil
+}
+
+// GetMany returns the recipients of the given users keyed by user_id; users
+// missing from the directory are absent from the map.
+func (r *RecipientRepository) GetMany(ctx context.Context, userIDs []string) (map[string]*domain.Recipient, error) {
+	rows, err := r.db.QueryWithRetry(ctx, r.retries,
+		`SELECT `+recipientColumns+` FROM recipients WHERE user_id = ANY($1)`, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recipients: %w", err)
+	}
+	defer rows.Close()
+
+	recipients := make(map[string]*domain.Recipient, len(userIDs))
+	for rows.Next() {
+		rcpt, err := scanRecipient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recipient: %w", err)
+		}
+		recipients[rcpt.UserID] = rcpt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating recipient rows: %w", err)
+	}
+	return recipients, nil
+}
+
+func (r *RecipientRepository) Delete(ctx context.Context, userID string) error {
+	res, err := r.db.ExecWithRetry(ctx, r.retries, `DELETE FROM recipients WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete recipient: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete recipient: %w", err)
+	}
+	if affected == 0 {
+		return domain.ErrRecipientNotFound
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+func (r *NotificationRepository) CreateSeries(ctx context.Context, series *domain.Series, first *domain.Notification) error {
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO notification_series (id, kind, expression, timezone, starts_at, until_at, status, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			series.ID, series.Recurrence.Kind, series.Recurrence.Expression, series.Recurrence.Timezone,
+			series.StartsAt, series.Recurrence.Until, series.Status, series.CreatedAt, series.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		inserted, err := insertNotification(ctx, tx, first)
+		if err != nil {
+			return err
+		}
+		if !inserted {
+			return &permanentError{err: domain.ErrIdempotencyKeyExists}
+		}
+		return nil
+	})
+	if errors.Is(err, domain.ErrIdempotencyKeyExists) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create notification series: %w", err)
+	}
+	r.cacheNotifications(ctx, first)
+	return nil
+}
+
+func (r *NotificationRepository) GetSeries(ctx context.Context, id string) (*domain.Series, error) {
+	row, err := r.db.QueryRowWithRetry(ctx, r.retries,
+		`SELECT id, kind, expression, timezone, starts_at, until_at, status, created_at, updated_at
+FROM notification_series WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification series: %w", err)
+	}
+	var series domain.Series
+	var until sql.NullTime
+	err = row.Scan(
+		&series.ID, &series.Recurrence.Kind, &series.Recurrence.Expression, &series.Recurrence.Timezone,
+		&series.StartsAt, &until, &series.Status, &series.CreatedAt, &series.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan notification series: %w", err)
+	}
+	if until.Valid {
+		series.Recurrence.Until = &until.Time
+	}
+	return &series, nil
+}
+
+// CompleteOccurrence sets the final status of a series occurrence and, while
+// the series is still active, schedules next in the same transaction. A nil
+// next marks the series as finished.
+func (r *NotificationRepository) CompleteOccurrence(
+	ctx context.Context,
+	id string,
+	status domain.NotificationStatus,
+	seriesID string,
+	next *domain.Notification,
+) error {
+	var completed *domain.Notification
+	scheduled := false
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		scheduled = false
+		now := time.Now()
+		var err error
+		completed, err = scanNotification(tx.QueryRowContext(ctx,
+			`UPDATE notifications SET status = $1, updated_at = $2 WHERE id = $3
+RETURNING `+notificationProjection,
+			status, now, id,
+		))
+		if err != nil {
+			return err
+		}
+		var seriesStatus domain.SeriesStatus
+		err = tx.QueryRowContext(ctx,
+			`SELECT status FROM notification_series WHERE id = $1 FOR UPDATE`, seriesID,
+		).Scan(&seriesStatus)
+		if err != nil {
+			return err
+		}
+		if seriesStatus != domain.SeriesActive {
+			return nil
+		}
+		if next == nil {
+			_, err = tx.ExecContext(ctx,
+				`UPDATE notification_series SET status = $1, updated_at = $2 WHERE id = $3`,
+				domain.SeriesFinished, now, seriesID,
+			)
+			return err
+		}
+		scheduled, err = insertNotification(ctx, tx, next)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete series occurrence: %w", err)
+	}
+	changed := []*domain.Notification{completed}
+	if scheduled {
+		changed = append(changed, next)
+	}
+	r.cacheNotifications(ctx, changed...)
+	return nil
+}
+
+// CancelSeries stops the series and cancels its pending occurrences. An
+// occurrence that is already being sent finishes, but no further ones are
+// scheduled after it.
+func (r *NotificationRepository) CancelSeries(ctx context.Context, seriesID string) error {
+	var cancelled []*domain.Notification
+	err := r.withTx(ctx, func(tx *sql.Tx) error {
+		cancelled = nil
+		now := time.Now()
+		_, err := tx.ExecContext(ctx,
+			`UPDATE notification_series SET status = $1, updated_at = $2 WHERE id = $3`,
+			domain.SeriesCancelled, now, seriesID,
+		)
+		if err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx,
+			`UPDATE notifications SET status = $1, updated_at = $2
+WHERE series_id = $3 AND status = $4
+RETURNING `+notificationProjection,
+			domain.StatusCancelled, now, seriesID, domain.StatusPending,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		cancelled, err = scanNotifications(rows)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel notification series: %w", err)
+	}
+	r.cacheNotifications(ctx, cancelled...)
+	return nil
+}
@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"delayed-notifier/internal/domain"
+
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/retry"
+)
+
+const templateColumns = `id, name, text_body, html_body, channel_bodies, created_at, updated_at`
+
+type TemplateRepository struct {
+	db      *dbpg.DB
+	retries retry.Strategy
+}
+
+func NewTemplateRepository(db *dbpg.DB, retries retry.Strategy) *TemplateRepository {
+	return &TemplateRepository{
+		db:      db,
+		retries: retries,
+	}
+}
+
+func scanTemplate(row rowScanner) (*domain.Template, error) {
+	var tmpl domain.Template
+	var htmlBody sql.NullString
+	var channelBodies []byte
+	err := row.Scan(&tmpl.ID, &tmpl.Name, &tmpl.TextBody, &htmlBody, &channelBodies, &tmpl.CreatedAt, &tmpl.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.HTMLBody = htmlBody.String
+	if err := json.Unmarshal(channelBodies, &tmpl.ChannelBodies); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal channel bodies: %w", err)
+	}
+	return &tmpl, nil
+}
+
+func channelBodiesJSON(bodies map[domain.NotificationChannel]string) ([]byte, error) {
+	if bodies == nil {
+		bodies = map[domain.NotificationChannel]string{}
+	}
+	data, err := json.Marshal(bodies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal channel bodies: %w", err)
+	}
+	return data, nil
+}
+
+func (r *TemplateRepository) Create(ctx context.Context, tmpl *domain.Template) error {
+	channelBodies, err := channelBodiesJSON(tmpl.ChannelBodies)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Master.ExecContext(ctx,
+		`INSERT INTO templates (`+templateColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		tmpl.ID, tmpl.Name, tmpl.TextBody, nullString(tmpl.HTMLBody), channelBodies, tmpl.CreatedAt, tmpl.UpdatedAt,
+	)
+	if isUniqueViolation(err) {
+		return domain.ErrTemplateExists
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create template: %w", err)
+	}
+	return nil
+}
+
+func (r *TemplateRepository) Get(ctx context.Context, id string) (*domain.Template, error) {
+	row, err := r.db.QueryRowWithRetry(ctx, r.retries,
+		`SELECT `+templateColumns+` FROM templates WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query template: %w", err)
+	}
+	tmpl, err := scanTemplate(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func (r *TemplateRepository) List(ctx context.Context) ([]*domain.Template, error) {
+	rows, err := r.db.QueryWithRetry(ctx, r.retries,
+		`SELECT `+templateColumns+` FROM templates ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	defer rows.Close()
+	var templates []*domain.Template
+	for rows.Next() {
+		tmpl, err := scanTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan template: %w", err)
+		}
+		templates = append(templates, tmpl)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating template rows: %w", err)
+	}
+	return templates, nil
+}
+
+func (r *TemplateRepository) Update(ctx context.Context, tmpl *domain.Template) (*domain.Template, error) {
+	channelBodies, err := channelBodiesJSON(tmpl.ChannelBodies)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := scanTemplate(r.db.Master.QueryRowContext(ctx,
+		`UPDATE templates SET name = $1, text_body = $2, html_body = $3, channel_bodies = $4, updated_at = $5
+WHERE id = $6
+RETURNING `+templateColumns,
+		tmpl.Name, tmpl.TextBody, nullString(tmpl.HTMLBody), channelBodies, tmpl.UpdatedAt, tmpl.ID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrTemplateNotFound
+	}
+	if isUniqueViolation(err) {
+		return nil, domain.ErrTemplateExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update template: %w", err)
+	}
+	return updated, nil
+}
+
+// Delete refuses to remove a template that pending notifications still render
+// from. The template row is locked first so that no new notification can
+// reference it between the check and the delete.
+func (r *TemplateRepository) Delete(ctx context.Context, id string) error {
+	err := r.db.WithTx(ctx, func(tx *sql.Tx) error {
+		var locked string
+		err := tx.QueryRowContext(ctx, `SELECT id FROM templates WHERE id = $1 FOR UPDATE`, id).Scan(&locked)
+		if err == sql.ErrNoRows {
+			return domain.ErrTemplateNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var inUse bool
+		err = tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM notifications WHERE template_id = $1 AND status IN ($2, $3))`,
+			id, domain.StatusPending, domain.StatusProcessing,
+		).Scan(&inUse)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return domain.ErrTemplateInUse
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM templates WHERE id = $1`, id)
+		return err
+	})
+	if errors.Is(err, domain.ErrTemplateNotFound) || errors.Is(err, domain.ErrTemplateInUse) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+	return nil
+}
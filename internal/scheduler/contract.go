@@ -0,0 +1,17 @@
+package scheduler
+
+import (
+	"context"
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+type NotificationRepository interface {
+	MarkOverdueRequeued(ctx context.Context, before, requeuedBefore time.Time, limit int) ([]*domain.Notification, error)
+	ReleaseExpiredClaims(ctx context.Context, before time.Time, limit int) ([]*domain.Notification, error)
+}
+
+type MessageBroker interface {
+	PublishDelayed(ctx context.Context, id string, version int, delay time.Duration) error
+}
@@ -1 +1,94 @@
 package scheduler
+
+import (
+	"context"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+type Config struct {
+	Interval     time.Duration
+	BatchSize    int
+	GracePeriod  time.Duration
+	LeaseTimeout time.Duration
+}
+
+type Scheduler struct {
+	repo   NotificationRepository
+	broker MessageBroker
+	cfg    Config
+}
+
+func NewScheduler(repo NotificationRepository, broker MessageBroker, cfg Config) *Scheduler {
+	return &Scheduler{
+		repo:   repo,
+		broker: broker,
+		cfg:    cfg,
+	}
+}
+
+func (s *Scheduler) Run(ctx context.Context) error {
+	zlog.Logger.Info().
+		Dur("interval", s.cfg.Interval).
+		Int("batch_size", s.cfg.BatchSize).
+		Msg("Starting recovery scheduler")
+
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			zlog.Logger.Info().Msg("Recovery scheduler stopped")
+			return ctx.Err()
+		case <-ticker.C:
+			s.reclaim(ctx)
+			s.sweep(ctx)
+		}
+	}
+}
+
+// reclaim returns notifications whose processing lease has expired, e.g. after
+// a worker crashed mid-send, back to pending and re-enqueues them.
+func (s *Scheduler) reclaim(ctx context.Context) {
+	before := time.Now().Add(-s.cfg.LeaseTimeout)
+	notifs, err := s.repo.ReleaseExpiredClaims(ctx, before, s.cfg.BatchSize)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("Failed to release expired claims")
+		return
+	}
+	for _, n := range notifs {
+		if err := s.broker.PublishDelayed(ctx, n.ID, n.Version, 0); err != nil {
+			zlog.Logger.Error().Err(err).Str("id", n.ID).Msg("Failed to re-enqueue reclaimed notification")
+		}
+	}
+	if len(notifs) > 0 {
+		zlog.Logger.Warn().Int("count", len(notifs)).Msg("Reclaimed notifications with expired processing lease")
+	}
+}
+
+// sweep re-enqueues overdue pending notifications. Rows touched within the
+// grace period are skipped so that messages still in flight are not duplicated,
+// and rows the sweep already requeued wait one lease timeout before the next
+// attempt, so slow workers are not flooded with copies.
+func (s *Scheduler) sweep(ctx context.Context) {
+	now := time.Now()
+	notifs, err := s.repo.MarkOverdueRequeued(ctx, now.Add(-s.cfg.GracePeriod), now.Add(-s.cfg.LeaseTimeout), s.cfg.BatchSize)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("Failed to fetch overdue notifications")
+		return
+	}
+	if len(notifs) == 0 {
+		return
+	}
+	requeued := 0
+	for _, n := range notifs {
+		if err := s.broker.PublishDelayed(ctx, n.ID, n.Version, 0); err != nil {
+			zlog.Logger.Error().Err(err).Str("id", n.ID).Msg("Failed to re-enqueue notification")
+			continue
+		}
+		requeued++
+	}
+	zlog.Logger.Info().Int("found", len(notifs)).Int("requeued", requeued).Msg("Recovery sweep finished")
+}
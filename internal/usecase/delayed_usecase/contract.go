@@ -2,25 +2,54 @@ package delayed_usecase
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"delayed-notifier/internal/domain"
 )
 
 type MessageBroker interface {
-	PublishDelayed(ctx context.Context, id string, delay time.Duration) error
+	PublishDelayed(ctx context.Context, id string, version int, delay time.Duration) error
 }
 
 type Notifier interface {
 	Send(ctx context.Context, notification *domain.Notification) error
 }
 
+type TemplateRenderer interface {
+	GetTemplate(ctx context.Context, id string) (*domain.Template, error)
+	Render(ctx context.Context, id string, channel domain.NotificationChannel, params map[string]any) (*domain.RenderedMessage, error)
+	RenderTemplate(tmpl *domain.Template, channel domain.NotificationChannel, params map[string]any) (*domain.RenderedMessage, error)
+}
+
+type RecipientResolver interface {
+	ResolveAddress(ctx context.Context, userID string, channel domain.NotificationChannel) (string, error)
+}
+
+type PreferenceChecker interface {
+	CheckDelivery(ctx context.Context, notif *domain.Notification, now time.Time) (domain.DeliveryDecision, error)
+}
+
 type NotificationRepository interface {
 	Create(ctx context.Context, notif *domain.Notification) error
+	CreateBatch(ctx context.Context, notifs []*domain.Notification) ([]bool, error)
 	Get(ctx context.Context, id string) (*domain.Notification, error)
+	GetByIdempotencyKey(ctx context.Context, clientID, key string) (*domain.Notification, error)
 	UpdateStatus(ctx context.Context, id string, status domain.NotificationStatus) error
+	Update(ctx context.Context, id string, upd *domain.UpdateNotification) (*domain.Notification, error)
+	Claim(ctx context.Context, id string, version int) (*domain.Notification, error)
+	ChannelOptions(ctx context.Context, id string) (json.RawMessage, error)
 	IncrementRetry(ctx context.Context, id string) error
+	AdvanceChannel(ctx context.Context, id string, channel domain.NotificationChannel) error
+	Defer(ctx context.Context, id string, sendAt time.Time) error
+	SetSuppressionReason(ctx context.Context, id string, reason string) error
 	Delete(ctx context.Context, id string) error
-	List(ctx context.Context) ([]*domain.Notification, error)
-	GetPendingNotifications(ctx context.Context) ([]*domain.Notification, error)
+	CancelMatching(ctx context.Context, filter domain.CancelFilter) (int, error)
+	List(ctx context.Context, filter domain.ListFilter) (*domain.NotificationPage, error)
+	CreateSeries(ctx context.Context, series *domain.Series, first *domain.Notification) error
+	GetSeries(ctx context.Context, id string) (*domain.Series, error)
+	CompleteOccurrence(ctx context.Context, id string, status domain.NotificationStatus, seriesID string, next *domain.Notification) error
+	CancelSeries(ctx context.Context, seriesID string) error
+	RecordAttempt(ctx context.Context, attempt *domain.DeliveryAttempt) error
+	ListAttempts(ctx context.Context, notificationID string) ([]*domain.DeliveryAttempt, error)
 }
@@ -2,6 +2,7 @@ package delayed_usecase
 
 import (
 	"context"
+	"errors"
 	"math"
 	"time"
 
@@ -12,11 +13,19 @@ import (
 	"github.com/wb-go/wbf/zlog"
 )
 
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
 type NotificationUsecase struct {
-	repo     NotificationRepository
-	broker   MessageBroker
-	retries  retry.Strategy
-	notifier Notifier
+	repo        NotificationRepository
+	broker      MessageBroker
+	retries     retry.Strategy
+	notifier    Notifier
+	templates   TemplateRenderer
+	recipients  RecipientResolver
+	preferences PreferenceChecker
 }
 
 func NewNotificationUsecase(
@@ -24,49 +33,204 @@ func NewNotificationUsecase(
 	broker MessageBroker,
 	retries retry.Strategy,
 	notifier Notifier,
+	templates TemplateRenderer,
+	recipients RecipientResolver,
+	preferences PreferenceChecker,
 ) *NotificationUsecase {
 	return &NotificationUsecase{
-		repo:     repo,
-		broker:   broker,
-		retries:  retries,
-		notifier: notifier,
+		repo:        repo,
+		broker:      broker,
+		retries:     retries,
+		notifier:    notifier,
+		templates:   templates,
+		recipients:  recipients,
+		preferences: preferences,
 	}
 }
 
-func (u *NotificationUsecase) CreateNotification(ctx context.Context, dto *domain.CreateNotification) (*domain.Notification, error) {
-	if dto.SendAt.Before(time.Now()) {
-		return nil, domain.ErrSendAtInPast
+// CreateNotification reports false when the request was an idempotent replay
+// and the returned notification is the one created originally.
+func (u *NotificationUsecase) CreateNotification(ctx context.Context, dto *domain.CreateNotification) (*domain.Notification, bool, error) {
+	var requestHash string
+	if dto.IdempotencyKey != "" {
+		requestHash = dto.RequestHash
+		existing, err := u.findIdempotent(ctx, dto.ClientID, dto.IdempotencyKey, requestHash)
+		if err != nil || existing != nil {
+			return existing, false, err
+		}
+	}
+	if err := u.validateContent(ctx, dto, map[string]*domain.Template{}); err != nil {
+		return nil, false, err
 	}
-	notif := &domain.Notification{
-		ID:        uuid.New().String(),
-		UserID:    dto.UserID,
-		Channel:   dto.Channel,
-		Message:   dto.Message,
-		SendAt:    dto.SendAt,
-		Status:    domain.StatusPending,
-		Retries:   0,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-	if err := u.repo.Create(ctx, notif); err != nil {
+	// Recurring notifications may omit send_at and start from the next
+	// occurrence after now.
+	if dto.SendAt.Before(time.Now()) && (dto.Recurrence == nil || !dto.SendAt.IsZero()) {
+		return nil, false, domain.ErrSendAtInPast
+	}
+	notif := newNotification(dto, requestHash)
+	// The broker message is enqueued by the outbox relay from the row written
+	// in the same transaction as the notification.
+	var err error
+	if dto.Recurrence != nil {
+		err = u.createSeries(ctx, notif, *dto.Recurrence)
+	} else {
+		err = u.repo.Create(ctx, notif)
+	}
+	if err != nil {
+		if errors.Is(err, domain.ErrIdempotencyKeyExists) {
+			// A concurrent request with the same key won the insert.
+			existing, err := u.findIdempotent(ctx, dto.ClientID, dto.IdempotencyKey, requestHash)
+			if err != nil {
+				return nil, false, err
+			}
+			if existing != nil {
+				return existing, false, nil
+			}
+			// Otherwise the insert was skipped because of the external_id.
+			if dto.ExternalID != "" {
+				return nil, false, domain.ErrExternalIDExists
+			}
+		}
+		return nil, false, err
+	}
+	return notif, true, nil
+}
+
+// CreateNotifications creates a batch of one-off notifications in a single
+// insert. Items that fail validation are reported in their result and do not
+// stop the rest of the batch; the returned error means nothing was created.
+func (u *NotificationUsecase) CreateNotifications(ctx context.Context, items []*domain.CreateNotification) ([]domain.BatchResult, error) {
+	results := make([]domain.BatchResult, len(items))
+	notifs := make([]*domain.Notification, 0, len(items))
+	templates := map[string]*domain.Template{}
+	now := time.Now()
+	for i, dto := range items {
+		if dto.Recurrence != nil {
+			results[i].Err = domain.ErrBatchRecurrence
+			continue
+		}
+		if err := u.validateContent(ctx, dto, templates); err != nil {
+			results[i].Err = err
+			continue
+		}
+		if dto.SendAt.Before(now) {
+			results[i].Err = domain.ErrSendAtInPast
+			continue
+		}
+		notif := newNotification(dto, "")
+		results[i].Notification = notif
+		notifs = append(notifs, notif)
+	}
+	inserted, err := u.repo.CreateBatch(ctx, notifs)
+	if err != nil {
 		return nil, err
 	}
-	delay := time.Until(notif.SendAt)
-	if err := u.broker.PublishDelayed(ctx, notif.ID, delay); err != nil {
-		zlog.Logger.Warn().Err(err).Str("id", notif.ID).Msg("Failed to publish to broker")
+	for i, j := 0, 0; i < len(results); i++ {
+		if results[i].Notification == nil {
+			continue
+		}
+		if !inserted[j] {
+			results[i] = domain.BatchResult{Err: domain.ErrExternalIDExists}
+		}
+		j++
 	}
-	return notif, nil
+	return results, nil
 }
 
-func (u *NotificationUsecase) GetNotificationStatus(ctx context.Context, id string) (domain.NotificationStatus, error) {
+func newNotification(dto *domain.CreateNotification, requestHash string) *domain.Notification {
+	now := time.Now()
+	return &domain.Notification{
+		ID:             uuid.New().String(),
+		UserID:         dto.UserID,
+		Channel:        dto.Channel,
+		Channels:       dto.Channels,
+		Category:       dto.Category,
+		Subject:        dto.Subject,
+		Message:        dto.Message,
+		SendAt:         dto.SendAt,
+		Status:         domain.StatusPending,
+		Retries:        0,
+		Version:        1,
+		Revision:       1,
+		TemplateID:     dto.TemplateID,
+		TemplateParams: dto.TemplateParams,
+		ChannelOptions: dto.ChannelOptions,
+		IdempotencyKey: dto.IdempotencyKey,
+		RequestHash:    requestHash,
+		ClientID:       dto.ClientID,
+		ExternalID:     dto.ExternalID,
+		Tags:           dto.Tags,
+		Metadata:       dto.Metadata,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// validateContent makes sure exactly one of message and template is given and
+// that the template renders with the supplied params for every channel of the
+// fallback chain. templates caches the templates read so far, so a batch reads
+// each of them once; a nil entry records a missing template.
+func (u *NotificationUsecase) validateContent(ctx context.Context, dto *domain.CreateNotification, templates map[string]*domain.Template) error {
+	if (dto.Message == "") == (dto.TemplateID == "") {
+		return domain.ErrMessageOrTemplateOnly
+	}
+	if dto.TemplateID == "" {
+		return nil
+	}
+	tmpl, ok := templates[dto.TemplateID]
+	if !ok {
+		var err error
+		tmpl, err = u.templates.GetTemplate(ctx, dto.TemplateID)
+		if err != nil && !errors.Is(err, domain.ErrTemplateNotFound) {
+			return err
+		}
+		templates[dto.TemplateID] = tmpl
+	}
+	if tmpl == nil {
+		return domain.ErrTemplateNotFound
+	}
+	chain := dto.Channels
+	if len(chain) == 0 {
+		chain = []domain.NotificationChannel{dto.Channel}
+	}
+	for _, ch := range chain {
+		if _, err := u.templates.RenderTemplate(tmpl, ch, dto.TemplateParams); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *NotificationUsecase) findIdempotent(ctx context.Context, clientID, key, requestHash string) (*domain.Notification, error) {
+	existing, err := u.repo.GetByIdempotencyKey(ctx, clientID, key)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, nil
+	}
+	if existing.RequestHash != requestHash {
+		return nil, domain.ErrIdempotencyConflict
+	}
+	return existing, nil
+}
+
+func (u *NotificationUsecase) GetNotification(ctx context.Context, id string) (*domain.Notification, error) {
 	notif, err := u.repo.Get(ctx, id)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if notif == nil {
-		return "", domain.ErrNotFound
+		return nil, domain.ErrNotFound
+	}
+	return notif, nil
+}
+
+func (u *NotificationUsecase) ListAttempts(ctx context.Context, id string) ([]*domain.DeliveryAttempt, error) {
+	if _, err := u.GetNotification(ctx, id); err != nil {
+		return nil, err
 	}
-	return notif.Status, nil
+	return u.repo.ListAttempts(ctx, id)
 }
 
 func (u *NotificationUsecase) CancelNotification(ctx context.Context, id string) error {
@@ -77,46 +241,283 @@ func (u *NotificationUsecase) CancelNotification(ctx context.Context, id string)
 	if notif == nil {
 		return domain.ErrNotFound
 	}
+	if notif.SeriesID != "" {
+		return u.cancelSeries(ctx, notif)
+	}
 	if notif.Status != domain.StatusPending {
 		return domain.ErrCannotCancel
 	}
 	return u.repo.UpdateStatus(ctx, id, domain.StatusCancelled)
 }
 
-func (u *NotificationUsecase) ListNotifications(ctx context.Context) ([]*domain.Notification, error) {
-	return u.repo.List(ctx)
+// CancelNotifications cancels all pending notifications matching the filter
+// and reports how many were cancelled.
+func (u *NotificationUsecase) CancelNotifications(ctx context.Context, filter domain.CancelFilter) (int, error) {
+	if filter.IsEmpty() {
+		return 0, domain.ErrEmptyFilter
+	}
+	return u.repo.CancelMatching(ctx, filter)
 }
 
-func (u *NotificationUsecase) ProcessNotification(ctx context.Context, id string) error {
-	notif, err := u.repo.Get(ctx, id)
+func (u *NotificationUsecase) UpdateNotification(ctx context.Context, id string, upd *domain.UpdateNotification) (*domain.Notification, error) {
+	if upd.Channel == nil && upd.Message == nil && upd.SendAt == nil {
+		return nil, domain.ErrNothingToUpdate
+	}
+	if upd.SendAt != nil && upd.SendAt.Before(time.Now()) {
+		return nil, domain.ErrSendAtInPast
+	}
+	notif, err := u.repo.Update(ctx, id, upd)
+	if err != nil {
+		return nil, err
+	}
+	if notif != nil {
+		return notif, nil
+	}
+	existing, err := u.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, domain.ErrNotFound
+	}
+	if upd.Message != nil && existing.TemplateID != "" && existing.Status == domain.StatusPending {
+		return nil, domain.ErrTemplateMessage
+	}
+	return nil, domain.ErrCannotUpdate
+}
+
+func (u *NotificationUsecase) ListNotifications(ctx context.Context, filter domain.ListFilter) (*domain.NotificationPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	return u.repo.List(ctx, filter)
+}
+
+// ProcessNotification delivers the notification if the message version still
+// matches the row. Version 0 comes from messages published before versioning
+// and is accepted for any version.
+func (u *NotificationUsecase) ProcessNotification(ctx context.Context, id string, version int) error {
+	// Only the delivery that moves the row from pending to processing may send;
+	// duplicates from concurrent workers or DLQ redelivery lose the claim.
+	notif, err := u.repo.Claim(ctx, id, version)
 	if err != nil {
 		return err
 	}
 	if notif == nil {
-		return domain.ErrNotFound
+		return u.handleUnclaimed(ctx, id, version)
 	}
-	if notif.Status != domain.StatusPending {
-		zlog.Logger.Info().Str("id", id).Str("status", string(notif.Status)).Msg("Notification already processed")
-		return nil
+	// The claimed row comes without attachments. The sender and the next
+	// occurrence of a series need the full options.
+	if len(notif.ChannelOptions) > 0 {
+		if notif.ChannelOptions, err = u.repo.ChannelOptions(ctx, id); err != nil {
+			return err
+		}
 	}
-	if notif.SendAt.After(time.Now()) {
-		delay := time.Until(notif.SendAt)
-		return u.broker.PublishDelayed(ctx, id, delay)
+	decision, err := u.preferences.CheckDelivery(ctx, notif, time.Now())
+	if err != nil {
+		return err
+	}
+	switch decision.Action {
+	case domain.DeliverLater:
+		notificationLogger(notif).Info().Time("until", decision.Until).Str("reason", decision.Reason).Msg("Deferring notification")
+		if merged, err := u.deferTo(ctx, notif, decision.Until); merged || err != nil {
+			return err
+		}
+		return u.broker.PublishDelayed(ctx, id, notif.Version, time.Until(decision.Until))
+	case domain.SkipChannel:
+		if _, ok := notif.NextChannel(); ok {
+			return u.fail(ctx, notif)
+		}
+		return u.suppress(ctx, notif, decision.Reason)
+	case domain.Suppress:
+		return u.suppress(ctx, notif, decision.Reason)
+	}
+	// The rendered copy is what gets sent; notif keeps the template reference
+	// for the next occurrence of a series.
+	outgoing := *notif
+	outgoing.ChannelOptions = domain.OptionsFor(notif.ChannelOptions, notif.Channel)
+	if notif.TemplateID != "" {
+		rendered, err := u.templates.Render(ctx, notif.TemplateID, notif.Channel, notif.TemplateParams)
+		if err != nil {
+			// The template was changed or removed after creation; retrying
+			// will not make it render, but it may still render for the next
+			// channel of the chain.
+			notificationLogger(notif).Error().Err(err).Str("template_id", notif.TemplateID).Msg("Failed to render template")
+			u.recordAttempt(ctx, notif, time.Now(), err)
+			return u.fail(ctx, notif)
+		}
+		outgoing.Message = rendered.Text
+		outgoing.HTMLMessage = rendered.HTML
 	}
-	err = retry.DoContext(ctx, u.retries, func() error {
-		return u.notifier.Send(ctx, notif)
-	})
+	outgoing.Address, err = u.recipients.ResolveAddress(ctx, notif.UserID, notif.Channel)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Str("id", id).Msg("Failed to send notification")
+		if !errors.Is(err, domain.ErrNoAddress) {
+			return err
+		}
+		// The address for this channel was removed from the directory after
+		// the notification was scheduled.
+		notificationLogger(notif).Error().Err(err).Str("user_id", notif.UserID).Msg("Failed to resolve recipient address")
+		u.recordAttempt(ctx, notif, time.Now(), err)
+		return u.fail(ctx, notif)
+	}
+	err = u.send(ctx, notif, &outgoing)
+	if err == nil {
+		return u.complete(ctx, notif, domain.StatusSent)
+	}
+	notificationLogger(notif).Error().Err(err).Msg("Failed to send notification")
+	var delay time.Duration
+	switch domain.DeliveryErrorKindOf(err) {
+	case domain.DeliveryPermanent:
 		if err := u.repo.IncrementRetry(ctx, id); err != nil {
 			return err
 		}
-		updatedNotif, _ := u.repo.Get(ctx, id)
-		if updatedNotif.Retries >= u.retries.Attempts {
-			return u.repo.UpdateStatus(ctx, id, domain.StatusFailed)
+		return u.fail(ctx, notif)
+	case domain.DeliveryRateLimited:
+		// Throttling says nothing about the notification itself, so it does
+		// not use up an attempt.
+		delay, _ = domain.RetryAfter(err)
+	default:
+		if err := u.repo.IncrementRetry(ctx, id); err != nil {
+			return err
+		}
+		retries := notif.Retries + 1
+		if retries >= u.retries.Attempts {
+			return u.fail(ctx, notif)
 		}
-		delay := u.retries.Delay * time.Duration(math.Pow(u.retries.Backoff, float64(updatedNotif.Retries-1)))
-		return u.broker.PublishDelayed(ctx, id, delay)
+		delay = u.retries.Delay * time.Duration(math.Pow(u.retries.Backoff, float64(retries-1)))
+	}
+	// Moving send_at keeps the recovery sweep from republishing the retry
+	// before the delay is over.
+	if merged, err := u.deferTo(ctx, notif, time.Now().Add(delay)); merged || err != nil {
+		return err
+	}
+	return u.broker.PublishDelayed(ctx, id, notif.Version, delay)
+}
+
+// deferTo moves send_at of the claimed notification. A series occurrence that
+// would land on another occurrence of its series is merged into it instead:
+// the other one delivers in its place and this one is suppressed. merged
+// reports that case.
+func (u *NotificationUsecase) deferTo(ctx context.Context, notif *domain.Notification, sendAt time.Time) (merged bool, err error) {
+	err = u.repo.Defer(ctx, notif.ID, sendAt)
+	if !errors.Is(err, domain.ErrOccurrenceExists) {
+		return false, err
+	}
+	// The next occurrence is computed after sendAt, past the one taking over.
+	notif.SendAt = sendAt
+	return true, u.suppress(ctx, notif, "merged into a later occurrence of the series")
+}
+
+// fail gives up on the current channel. Delivery moves on to the next channel
+// of the fallback chain right away; the notification fails only when the chain
+// is exhausted.
+func (u *NotificationUsecase) fail(ctx context.Context, notif *domain.Notification) error {
+	next, ok := notif.NextChannel()
+	if !ok {
+		return u.complete(ctx, notif, domain.StatusFailed)
+	}
+	notificationLogger(notif).Info().
+		Str("from", string(notif.Channel)).
+		Str("to", string(next)).
+		Msg("Falling back to next channel")
+	if err := u.repo.AdvanceChannel(ctx, notif.ID, next); err != nil {
+		return err
+	}
+	return u.broker.PublishDelayed(ctx, notif.ID, notif.Version, 0)
+}
+
+func (u *NotificationUsecase) suppress(ctx context.Context, notif *domain.Notification, reason string) error {
+	notificationLogger(notif).Info().Str("reason", reason).Msg("Suppressing notification")
+	if err := u.repo.SetSuppressionReason(ctx, notif.ID, reason); err != nil {
+		return err
+	}
+	return u.complete(ctx, notif, domain.StatusSuppressed)
+}
+
+// send runs the in-process retries for a claimed notification. Permanent and
+// rate-limited errors end the loop early: the former will not succeed and the
+// latter is waited out in the broker rather than in the worker.
+func (u *NotificationUsecase) send(ctx context.Context, notif, outgoing *domain.Notification) error {
+	delay := u.retries.Delay
+	var err error
+	for i := 0; i < u.retries.Attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay = time.Duration(float64(delay) * u.retries.Backoff)
+		}
+		started := time.Now()
+		err = u.notifier.Send(ctx, outgoing)
+		u.recordAttempt(ctx, notif, started, err)
+		if err == nil || domain.DeliveryErrorKindOf(err) != domain.DeliveryTransient {
+			return err
+		}
+	}
+	return err
+}
+
+// recordAttempt stores the outcome of a single delivery attempt. The history is
+// informational, so a failure to store it does not affect delivery.
+func (u *NotificationUsecase) recordAttempt(ctx context.Context, notif *domain.Notification, started time.Time, sendErr error) {
+	attempt := &domain.DeliveryAttempt{
+		NotificationID: notif.ID,
+		Channel:        notif.Channel,
+		StartedAt:      started,
+		Duration:       time.Since(started),
+		Success:        sendErr == nil,
+		ProviderCode:   domain.ProviderCode(sendErr),
+	}
+	if sendErr != nil {
+		attempt.Error = sendErr.Error()
+	}
+	if err := u.repo.RecordAttempt(ctx, attempt); err != nil {
+		notificationLogger(notif).Error().Err(err).Msg("Failed to record delivery attempt")
+	}
+}
+
+// notificationLogger returns a logger carrying the notification's business
+// context, so delivery logs can be traced back to the order or campaign.
+func notificationLogger(notif *domain.Notification) *zlog.Zerolog {
+	logCtx := zlog.Logger.With().Str("id", notif.ID)
+	if notif.ExternalID != "" {
+		logCtx = logCtx.Str("external_id", notif.ExternalID)
+	}
+	if len(notif.Tags) > 0 {
+		logCtx = logCtx.Strs("tags", notif.Tags)
+	}
+	if len(notif.Metadata) > 0 {
+		logCtx = logCtx.Interface("metadata", notif.Metadata)
+	}
+	logger := logCtx.Logger()
+	return &logger
+}
+
+func (u *NotificationUsecase) handleUnclaimed(ctx context.Context, id string, version int) error {
+	notif, err := u.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if notif == nil {
+		return domain.ErrNotFound
+	}
+	if version != 0 && version != notif.Version {
+		zlog.Logger.Info().
+			Str("id", id).
+			Int("message_version", version).
+			Int("current_version", notif.Version).
+			Msg("Skipping stale message for rescheduled notification")
+		return nil
+	}
+	if notif.Status == domain.StatusPending && notif.SendAt.After(time.Now()) {
+		delay := time.Until(notif.SendAt)
+		return u.broker.PublishDelayed(ctx, id, notif.Version, delay)
 	}
-	return u.repo.UpdateStatus(ctx, id, domain.StatusSent)
+	zlog.Logger.Info().Str("id", id).Str("status", string(notif.Status)).Msg("Notification already claimed or processed")
+	return nil
 }
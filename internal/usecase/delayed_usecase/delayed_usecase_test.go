@@ -0,0 +1,554 @@
+package delayed_usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"delayed-notifier/internal/domain"
+
+	"github.com/wb-go/wbf/retry"
+)
+
+// memoryRepo keeps notifications in memory with the claim and status
+// semantics of the Postgres repository. Methods the delivery path does not use
+// are left to the embedded interface and panic when called.
+type memoryRepo struct {
+	NotificationRepository
+	notifs   map[string]*domain.Notification
+	attempts []*domain.DeliveryAttempt
+}
+
+func newMemoryRepo(notifs ...*domain.Notification) *memoryRepo {
+	r := &memoryRepo{notifs: map[string]*domain.Notification{}}
+	for _, n := range notifs {
+		r.notifs[n.ID] = n
+	}
+	return r
+}
+
+func (r *memoryRepo) CreateBatch(_ context.Context, notifs []*domain.Notification) ([]bool, error) {
+	inserted := make([]bool, len(notifs))
+	for i, n := range notifs {
+		r.notifs[n.ID] = n
+		inserted[i] = true
+	}
+	return inserted, nil
+}
+
+func (r *memoryRepo) Get(_ context.Context, id string) (*domain.Notification, error) {
+	n, ok := r.notifs[id]
+	if !ok {
+		return nil, nil
+	}
+	cp := *n
+	return &cp, nil
+}
+
+func (r *memoryRepo) Claim(_ context.Context, id string, version int) (*domain.Notification, error) {
+	n, ok := r.notifs[id]
+	if !ok || n.Status != domain.StatusPending || n.SendAt.After(time.Now()) || (version != 0 && version != n.Version) {
+		return nil, nil
+	}
+	n.Status = domain.StatusProcessing
+	cp := *n
+	return &cp, nil
+}
+
+// releaseExpiredClaim mirrors ReleaseExpiredClaims for a single row.
+func (r *memoryRepo) releaseExpiredClaim(id string) {
+	if n := r.notifs[id]; n.Status == domain.StatusProcessing {
+		n.Status = domain.StatusPending
+	}
+}
+
+func (r *memoryRepo) ChannelOptions(_ context.Context, id string) (json.RawMessage, error) {
+	return r.notifs[id].ChannelOptions, nil
+}
+
+func (r *memoryRepo) UpdateStatus(_ context.Context, id string, status domain.NotificationStatus) error {
+	r.notifs[id].Status = status
+	return nil
+}
+
+func (r *memoryRepo) IncrementRetry(_ context.Context, id string) error {
+	r.notifs[id].Retries++
+	return nil
+}
+
+func (r *memoryRepo) AdvanceChannel(_ context.Context, id string, channel domain.NotificationChannel) error {
+	n := r.notifs[id]
+	n.Channel, n.Retries, n.Status = channel, 0, domain.StatusPending
+	return nil
+}
+
+func (r *memoryRepo) Defer(_ context.Context, id string, sendAt time.Time) error {
+	n := r.notifs[id]
+	for _, o := range r.notifs {
+		if o.ID != id && o.SeriesID != "" && o.SeriesID == n.SeriesID && o.SendAt.Equal(sendAt) {
+			return domain.ErrOccurrenceExists
+		}
+	}
+	n.Status, n.SendAt = domain.StatusPending, sendAt
+	return nil
+}
+
+func (r *memoryRepo) SetSuppressionReason(_ context.Context, id string, reason string) error {
+	r.notifs[id].SuppressionReason = reason
+	return nil
+}
+
+func (r *memoryRepo) GetSeries(context.Context, string) (*domain.Series, error) {
+	return nil, nil
+}
+
+func (r *memoryRepo) RecordAttempt(_ context.Context, attempt *domain.DeliveryAttempt) error {
+	r.attempts = append(r.attempts, attempt)
+	return nil
+}
+
+type published struct {
+	id      string
+	version int
+	delay   time.Duration
+}
+
+type memoryBroker struct {
+	messages []published
+}
+
+func (b *memoryBroker) PublishDelayed(_ context.Context, id string, version int, delay time.Duration) error {
+	b.messages = append(b.messages, published{id: id, version: version, delay: delay})
+	return nil
+}
+
+// fakeNotifier fails sends to the channels in errs and records the rest in
+// sent; calls records every send.
+type fakeNotifier struct {
+	errs  map[domain.NotificationChannel]error
+	sent  []domain.Notification
+	calls []domain.Notification
+}
+
+func (n *fakeNotifier) Send(_ context.Context, notif *domain.Notification) error {
+	n.calls = append(n.calls, *notif)
+	if err := n.errs[notif.Channel]; err != nil {
+		return err
+	}
+	n.sent = append(n.sent, *notif)
+	return nil
+}
+
+// fakeTemplates renders "<id> for <channel>" for every template except
+// "missing", fails the channels in errs and counts template reads.
+type fakeTemplates struct {
+	errs  map[domain.NotificationChannel]error
+	reads map[string]int
+}
+
+func (t fakeTemplates) GetTemplate(_ context.Context, id string) (*domain.Template, error) {
+	t.reads[id]++
+	if id == "missing" {
+		return nil, domain.ErrTemplateNotFound
+	}
+	return &domain.Template{ID: id}, nil
+}
+
+func (t fakeTemplates) Render(ctx context.Context, id string, channel domain.NotificationChannel, params map[string]any) (*domain.RenderedMessage, error) {
+	tmpl, err := t.GetTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return t.RenderTemplate(tmpl, channel, params)
+}
+
+func (t fakeTemplates) RenderTemplate(tmpl *domain.Template, channel domain.NotificationChannel, _ map[string]any) (*domain.RenderedMessage, error) {
+	if err := t.errs[channel]; err != nil {
+		return nil, err
+	}
+	return &domain.RenderedMessage{Text: tmpl.ID + " for " + string(channel)}, nil
+}
+
+// userAddresses resolves every user to its own ID, as for users missing from
+// the recipient directory.
+type userAddresses struct{}
+
+func (userAddresses) ResolveAddress(_ context.Context, userID string, _ domain.NotificationChannel) (string, error) {
+	return userID, nil
+}
+
+// fixedDecision answers every delivery check with the same decision.
+type fixedDecision struct {
+	decision domain.DeliveryDecision
+}
+
+func (p *fixedDecision) CheckDelivery(context.Context, *domain.Notification, time.Time) (domain.DeliveryDecision, error) {
+	return p.decision, nil
+}
+
+type testEnv struct {
+	repo        *memoryRepo
+	broker      *memoryBroker
+	notifier    *fakeNotifier
+	templates   fakeTemplates
+	preferences *fixedDecision
+	usecase     *NotificationUsecase
+}
+
+func newTestEnv(notifs ...*domain.Notification) *testEnv {
+	env := &testEnv{
+		repo:        newMemoryRepo(notifs...),
+		broker:      &memoryBroker{},
+		notifier:    &fakeNotifier{errs: map[domain.NotificationChannel]error{}},
+		templates:   fakeTemplates{errs: map[domain.NotificationChannel]error{}, reads: map[string]int{}},
+		preferences: &fixedDecision{decision: domain.DeliveryDecision{Action: domain.DeliverNow}},
+	}
+	env.usecase = NewNotificationUsecase(
+		env.repo,
+		env.broker,
+		retry.Strategy{Attempts: 2, Delay: time.Millisecond, Backoff: 2},
+		env.notifier,
+		env.templates,
+		userAddresses{},
+		env.preferences,
+	)
+	return env
+}
+
+func dueNotification(channels ...domain.NotificationChannel) *domain.Notification {
+	return &domain.Notification{
+		ID:       "6f1c2a9e-3b7d-4c55-9a51-0f2f4b8d1e77",
+		UserID:   "user-1",
+		Channel:  channels[0],
+		Channels: channels,
+		Message:  "hello",
+		SendAt:   time.Now().Add(-time.Second),
+		Status:   domain.StatusPending,
+		Version:  2,
+	}
+}
+
+func TestProcessNotificationDelivers(t *testing.T) {
+	notif := dueNotification(domain.ChannelEmail)
+	env := newTestEnv(notif)
+
+	if err := env.usecase.ProcessNotification(context.Background(), notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+	if notif.Status != domain.StatusSent {
+		t.Fatalf("status = %q, want sent", notif.Status)
+	}
+	if len(env.notifier.sent) != 1 || env.notifier.sent[0].Address != "user-1" {
+		t.Fatalf("sent = %+v, want one send to user-1", env.notifier.sent)
+	}
+	if len(env.repo.attempts) != 1 || !env.repo.attempts[0].Success {
+		t.Fatalf("attempts = %+v, want one successful attempt", env.repo.attempts)
+	}
+}
+
+func TestProcessNotificationClaimsOnce(t *testing.T) {
+	notif := dueNotification(domain.ChannelEmail)
+	env := newTestEnv(notif)
+	ctx := context.Background()
+
+	// The second delivery of the same message, e.g. a DLQ redelivery, finds
+	// the row already claimed and must not send again.
+	for i := 0; i < 2; i++ {
+		if err := env.usecase.ProcessNotification(ctx, notif.ID, notif.Version); err != nil {
+			t.Fatalf("ProcessNotification() #%d error = %v", i+1, err)
+		}
+	}
+	if len(env.notifier.sent) != 1 {
+		t.Fatalf("sent %d times, want once", len(env.notifier.sent))
+	}
+	if len(env.broker.messages) != 0 {
+		t.Fatalf("republished %+v", env.broker.messages)
+	}
+}
+
+func TestProcessNotificationSkipsStaleVersion(t *testing.T) {
+	notif := dueNotification(domain.ChannelEmail)
+	env := newTestEnv(notif)
+
+	if err := env.usecase.ProcessNotification(context.Background(), notif.ID, notif.Version-1); err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+	if len(env.notifier.sent) != 0 || len(env.broker.messages) != 0 {
+		t.Fatalf("stale message was acted on: sent %+v, published %+v", env.notifier.sent, env.broker.messages)
+	}
+	if notif.Status != domain.StatusPending {
+		t.Fatalf("status = %q, want pending", notif.Status)
+	}
+}
+
+func TestProcessNotificationRequeuesEarlyMessage(t *testing.T) {
+	notif := dueNotification(domain.ChannelEmail)
+	notif.SendAt = time.Now().Add(time.Hour)
+	env := newTestEnv(notif)
+
+	if err := env.usecase.ProcessNotification(context.Background(), notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+	if len(env.notifier.sent) != 0 {
+		t.Fatal("notification sent before send_at")
+	}
+	if len(env.broker.messages) != 1 || env.broker.messages[0].delay <= 0 {
+		t.Fatalf("published %+v, want one delayed message", env.broker.messages)
+	}
+}
+
+func TestProcessNotificationAfterLeaseExpiry(t *testing.T) {
+	notif := dueNotification(domain.ChannelEmail)
+	notif.Status = domain.StatusProcessing
+	env := newTestEnv(notif)
+	ctx := context.Background()
+
+	// A worker died holding the claim: nothing is sent until the lease is
+	// released, after which the republished message claims the row again.
+	if err := env.usecase.ProcessNotification(ctx, notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+	if len(env.notifier.sent) != 0 {
+		t.Fatal("notification sent while claimed by another worker")
+	}
+	env.repo.releaseExpiredClaim(notif.ID)
+	if err := env.usecase.ProcessNotification(ctx, notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() after release error = %v", err)
+	}
+	if len(env.notifier.sent) != 1 || notif.Status != domain.StatusSent {
+		t.Fatalf("sent %d times with status %q, want one send and sent", len(env.notifier.sent), notif.Status)
+	}
+}
+
+func TestProcessNotificationFallsBackOnPermanentError(t *testing.T) {
+	notif := dueNotification(domain.ChannelTelegram, domain.ChannelEmail)
+	env := newTestEnv(notif)
+	env.notifier.errs[domain.ChannelTelegram] = domain.PermanentError(errors.New("chat not found"))
+	ctx := context.Background()
+
+	if err := env.usecase.ProcessNotification(ctx, notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+	if notif.Channel != domain.ChannelEmail || notif.Status != domain.StatusPending || notif.Retries != 0 {
+		t.Fatalf("notification = %+v, want pending on email with fresh retries", notif)
+	}
+	if len(env.repo.attempts) != 1 {
+		t.Fatalf("%d attempts, want no in-process retry of a permanent error", len(env.repo.attempts))
+	}
+	if len(env.broker.messages) != 1 || env.broker.messages[0].delay != 0 {
+		t.Fatalf("published %+v, want an immediate message", env.broker.messages)
+	}
+
+	if err := env.usecase.ProcessNotification(ctx, notif.ID, env.broker.messages[0].version); err != nil {
+		t.Fatalf("ProcessNotification() on fallback error = %v", err)
+	}
+	if notif.Status != domain.StatusSent || len(env.notifier.sent) != 1 || env.notifier.sent[0].Channel != domain.ChannelEmail {
+		t.Fatalf("status %q, sent %+v, want delivery by email", notif.Status, env.notifier.sent)
+	}
+}
+
+func TestProcessNotificationFailsAtEndOfChain(t *testing.T) {
+	notif := dueNotification(domain.ChannelEmail)
+	env := newTestEnv(notif)
+	env.notifier.errs[domain.ChannelEmail] = domain.PermanentError(errors.New("mailbox does not exist"))
+
+	if err := env.usecase.ProcessNotification(context.Background(), notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+	if notif.Status != domain.StatusFailed {
+		t.Fatalf("status = %q, want failed", notif.Status)
+	}
+	if len(env.broker.messages) != 0 {
+		t.Fatalf("published %+v after the chain was exhausted", env.broker.messages)
+	}
+}
+
+func TestProcessNotificationRetriesTransientError(t *testing.T) {
+	notif := dueNotification(domain.ChannelEmail)
+	env := newTestEnv(notif)
+	env.notifier.errs[domain.ChannelEmail] = errors.New("connection reset")
+	ctx := context.Background()
+
+	started := time.Now()
+	if err := env.usecase.ProcessNotification(ctx, notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+	if len(env.repo.attempts) != 2 {
+		t.Fatalf("%d attempts, want the in-process retries", len(env.repo.attempts))
+	}
+	if notif.Status != domain.StatusPending || notif.Retries != 1 || !notif.SendAt.After(started) {
+		t.Fatalf("notification = %+v, want pending with send_at moved forward", notif)
+	}
+	if len(env.broker.messages) != 1 || env.broker.messages[0].delay <= 0 {
+		t.Fatalf("published %+v, want one delayed retry", env.broker.messages)
+	}
+
+	// The retry exhausts the attempts and fails the single-channel chain.
+	notif.SendAt = time.Now()
+	if err := env.usecase.ProcessNotification(ctx, notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() retry error = %v", err)
+	}
+	if notif.Status != domain.StatusFailed || notif.Retries != 2 {
+		t.Fatalf("notification = %+v, want failed after %d retries", notif, 2)
+	}
+}
+
+func TestProcessNotificationRateLimitKeepsAttempts(t *testing.T) {
+	notif := dueNotification(domain.ChannelTelegram)
+	env := newTestEnv(notif)
+	env.notifier.errs[domain.ChannelTelegram] = domain.RateLimitedError(errors.New("too many requests"), 30*time.Second)
+
+	if err := env.usecase.ProcessNotification(context.Background(), notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+	if notif.Retries != 0 || notif.Status != domain.StatusPending {
+		t.Fatalf("notification = %+v, want pending without a used retry", notif)
+	}
+	if len(env.broker.messages) != 1 || env.broker.messages[0].delay != 30*time.Second {
+		t.Fatalf("published %+v, want a retry after 30s", env.broker.messages)
+	}
+}
+
+func TestProcessNotificationFallsBackWhenTemplateFails(t *testing.T) {
+	notif := dueNotification(domain.ChannelTelegram, domain.ChannelEmail)
+	notif.Message = ""
+	notif.TemplateID = "order-shipped"
+	env := newTestEnv(notif)
+	env.templates.errs[domain.ChannelTelegram] = domain.ErrTemplateParams
+	ctx := context.Background()
+
+	if err := env.usecase.ProcessNotification(ctx, notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+	if notif.Channel != domain.ChannelEmail || notif.Status != domain.StatusPending {
+		t.Fatalf("notification = %+v, want pending on email", notif)
+	}
+	if err := env.usecase.ProcessNotification(ctx, notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() on fallback error = %v", err)
+	}
+	if notif.Status != domain.StatusSent || len(env.notifier.sent) != 1 || env.notifier.sent[0].Message != "order-shipped for email" {
+		t.Fatalf("status %q, sent %+v, want the email rendering sent", notif.Status, env.notifier.sent)
+	}
+}
+
+func TestProcessNotificationPassesEachChannelItsOptions(t *testing.T) {
+	notif := dueNotification(domain.ChannelTelegram, domain.ChannelEmail)
+	notif.ChannelOptions = json.RawMessage(`{"telegram":{"silent":true},"email":{"attachments":[{"filename":"a.txt","content":"eA=="}]}}`)
+	env := newTestEnv(notif)
+	env.notifier.errs[domain.ChannelTelegram] = domain.PermanentError(errors.New("bot was blocked"))
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := env.usecase.ProcessNotification(ctx, notif.ID, notif.Version); err != nil {
+			t.Fatalf("ProcessNotification() #%d error = %v", i+1, err)
+		}
+	}
+	want := map[domain.NotificationChannel]string{
+		domain.ChannelTelegram: `{"silent":true}`,
+		domain.ChannelEmail:    `{"attachments":[{"filename":"a.txt","content":"eA=="}]}`,
+	}
+	if len(env.notifier.calls) != 2 {
+		t.Fatalf("%d sends, want telegram then email", len(env.notifier.calls))
+	}
+	for _, call := range env.notifier.calls {
+		var got bytes.Buffer
+		if err := json.Compact(&got, call.ChannelOptions); err != nil {
+			t.Fatalf("%s options %q are not JSON: %v", call.Channel, call.ChannelOptions, err)
+		}
+		if got.String() != want[call.Channel] {
+			t.Fatalf("%s got options %s, want %s", call.Channel, got.String(), want[call.Channel])
+		}
+	}
+	// The stored options stay keyed, so the next occurrence of a series and
+	// later fallbacks still see every channel's part.
+	if stored := env.repo.notifs[notif.ID].ChannelOptions; !bytes.Contains(stored, []byte(`"telegram"`)) {
+		t.Fatalf("stored options = %s, want the keyed original", stored)
+	}
+}
+
+func TestCreateNotificationsReadsEachTemplateOnce(t *testing.T) {
+	env := newTestEnv()
+	sendAt := time.Now().Add(time.Hour)
+	var items []*domain.CreateNotification
+	for i := 0; i < 3; i++ {
+		items = append(items, &domain.CreateNotification{
+			UserID:     "user-1",
+			Channels:   []domain.NotificationChannel{domain.ChannelTelegram, domain.ChannelEmail},
+			Channel:    domain.ChannelTelegram,
+			TemplateID: "order-shipped",
+			SendAt:     sendAt,
+		})
+	}
+	items = append(items,
+		&domain.CreateNotification{UserID: "user-2", Channel: domain.ChannelEmail, TemplateID: "missing", SendAt: sendAt},
+		&domain.CreateNotification{UserID: "user-3", Channel: domain.ChannelEmail, TemplateID: "missing", SendAt: sendAt},
+	)
+
+	results, err := env.usecase.CreateNotifications(context.Background(), items)
+	if err != nil {
+		t.Fatalf("CreateNotifications() error = %v", err)
+	}
+	for i, res := range results {
+		wantErr := i >= 3
+		if gotErr := res.Err != nil; gotErr != wantErr {
+			t.Fatalf("item %d error = %v, want error %v", i, res.Err, wantErr)
+		}
+		if wantErr && !errors.Is(res.Err, domain.ErrTemplateNotFound) {
+			t.Fatalf("item %d error = %v, want ErrTemplateNotFound", i, res.Err)
+		}
+	}
+	if env.templates.reads["order-shipped"] != 1 || env.templates.reads["missing"] != 1 {
+		t.Fatalf("template reads = %v, want one per template", env.templates.reads)
+	}
+}
+
+func TestProcessNotificationMergesDeferredOccurrence(t *testing.T) {
+	until := time.Now().Add(time.Hour).Truncate(time.Second)
+	notif := dueNotification(domain.ChannelEmail)
+	notif.SeriesID = "series-1"
+	later := &domain.Notification{
+		ID:       "b2d4f1c0-8e3a-4f6b-9c27-5a1e0d9f3b64",
+		UserID:   "user-1",
+		Channel:  domain.ChannelEmail,
+		Message:  "hello",
+		SendAt:   until,
+		Status:   domain.StatusPending,
+		Version:  1,
+		SeriesID: "series-1",
+	}
+	env := newTestEnv(notif, later)
+	env.preferences.decision = domain.DeliveryDecision{Action: domain.DeliverLater, Until: until, Reason: "quiet hours"}
+
+	if err := env.usecase.ProcessNotification(context.Background(), notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+	if notif.Status != domain.StatusSuppressed || notif.SuppressionReason == "" {
+		t.Fatalf("notification = %+v, want suppressed in favor of the later occurrence", notif)
+	}
+	if later.Status != domain.StatusPending || !later.SendAt.Equal(until) {
+		t.Fatalf("later occurrence = %+v, want it untouched", later)
+	}
+	if len(env.broker.messages) != 0 || len(env.notifier.sent) != 0 {
+		t.Fatalf("published %+v, sent %+v, want neither", env.broker.messages, env.notifier.sent)
+	}
+}
+
+func TestProcessNotificationDefersDuringQuietHours(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	notif := dueNotification(domain.ChannelEmail)
+	env := newTestEnv(notif)
+	env.preferences.decision = domain.DeliveryDecision{Action: domain.DeliverLater, Until: until, Reason: "quiet hours"}
+
+	if err := env.usecase.ProcessNotification(context.Background(), notif.ID, notif.Version); err != nil {
+		t.Fatalf("ProcessNotification() error = %v", err)
+	}
+	if notif.Status != domain.StatusPending || !notif.SendAt.Equal(until) {
+		t.Fatalf("notification = %+v, want pending until %v", notif, until)
+	}
+	if len(env.broker.messages) != 1 || env.broker.messages[0].delay <= 0 {
+		t.Fatalf("published %+v, want one delayed message", env.broker.messages)
+	}
+}
@@ -0,0 +1,111 @@
+package delayed_usecase
+
+import (
+	"context"
+	"time"
+
+	"delayed-notifier/internal/domain"
+	"delayed-notifier/internal/usecase/recurrence"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/zlog"
+)
+
+func (u *NotificationUsecase) createSeries(ctx context.Context, first *domain.Notification, rec domain.Recurrence) error {
+	if rec.Timezone == "" {
+		rec.Timezone = "UTC"
+	}
+	startsAt := first.SendAt
+	if startsAt.IsZero() {
+		startsAt = first.CreatedAt
+	}
+	sendAt, ok, err := recurrence.Next(rec, startsAt, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return domain.ErrNoOccurrences
+	}
+	series := &domain.Series{
+		ID:         uuid.New().String(),
+		Recurrence: rec,
+		StartsAt:   startsAt,
+		Status:     domain.SeriesActive,
+		CreatedAt:  first.CreatedAt,
+		UpdatedAt:  first.CreatedAt,
+	}
+	first.SeriesID = series.ID
+	first.SendAt = sendAt
+	return u.repo.CreateSeries(ctx, series, first)
+}
+
+// complete records the final status of notif. For a series occurrence it also
+// materializes the next occurrence, skipping any that were missed while the
+// occurrence was being delivered.
+func (u *NotificationUsecase) complete(ctx context.Context, notif *domain.Notification, status domain.NotificationStatus) error {
+	if notif.SeriesID == "" {
+		return u.repo.UpdateStatus(ctx, notif.ID, status)
+	}
+	series, err := u.repo.GetSeries(ctx, notif.SeriesID)
+	if err != nil {
+		return err
+	}
+	if series == nil {
+		return u.repo.UpdateStatus(ctx, notif.ID, status)
+	}
+	var next *domain.Notification
+	if series.Status == domain.SeriesActive {
+		now := time.Now()
+		after := notif.SendAt.Add(time.Nanosecond)
+		if now.After(after) {
+			after = now
+		}
+		sendAt, ok, err := recurrence.Next(series.Recurrence, series.StartsAt, after)
+		if err != nil {
+			zlog.Logger.Error().Err(err).Str("series_id", series.ID).Msg("Failed to compute next occurrence")
+		}
+		if ok {
+			// Every occurrence starts from the head of the fallback chain. The
+			// external_id stays with the first occurrence; later ones are tied
+			// to it through the series.
+			channel := notif.Channel
+			if len(notif.Channels) > 0 {
+				channel = notif.Channels[0]
+			}
+			next = &domain.Notification{
+				ID:             uuid.New().String(),
+				UserID:         notif.UserID,
+				Channel:        channel,
+				Channels:       notif.Channels,
+				Category:       notif.Category,
+				Subject:        notif.Subject,
+				Message:        notif.Message,
+				SendAt:         sendAt,
+				Status:         domain.StatusPending,
+				Version:        1,
+				Revision:       1,
+				SeriesID:       series.ID,
+				TemplateID:     notif.TemplateID,
+				TemplateParams: notif.TemplateParams,
+				ChannelOptions: notif.ChannelOptions,
+				ClientID:       notif.ClientID,
+				Tags:           notif.Tags,
+				Metadata:       notif.Metadata,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			}
+		}
+	}
+	return u.repo.CompleteOccurrence(ctx, notif.ID, status, series.ID, next)
+}
+
+func (u *NotificationUsecase) cancelSeries(ctx context.Context, notif *domain.Notification) error {
+	series, err := u.repo.GetSeries(ctx, notif.SeriesID)
+	if err != nil {
+		return err
+	}
+	if series == nil || series.Status != domain.SeriesActive {
+		return domain.ErrCannotCancel
+	}
+	return u.repo.CancelSeries(ctx, series.ID)
+}
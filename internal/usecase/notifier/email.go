@@ -2,16 +2,22 @@ package notifier
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"net/smtp"
+	"net/mail"
+	"net/textproto"
+	"time"
 
+	"delayed-notifier/internal/config"
 	"delayed-notifier/internal/domain"
 
 	"github.com/wb-go/wbf/zlog"
 )
 
 type EmailNotifier struct {
-	cfg EmailConfig
+	cfg  EmailConfig
+	pool *smtpPool
 }
 
 type EmailConfig struct {
@@ -19,25 +25,98 @@ type EmailConfig struct {
 	SmtpPort int
 	User     string
 	Pass     string
+	// TLSMode is one of SMTPTLSNone, SMTPTLSStartTLS or SMTPTLSImplicit and
+	// AuthMethod one of SMTPAuthPlain, SMTPAuthLogin or SMTPAuthCRAMMD5.
+	TLSMode    string
+	AuthMethod string
+	// PoolSize is the number of idle connections kept open; Timeout bounds
+	// every network operation.
+	PoolSize    int
+	IdleTimeout time.Duration
+	Timeout     time.Duration
 }
 
 func NewEmailNotifier(cfg EmailConfig) *EmailNotifier {
-	return &EmailNotifier{cfg: cfg}
+	return &EmailNotifier{
+		cfg:  cfg,
+		pool: newSMTPPool(cfg),
+	}
+}
+
+func emailChannel() Channel {
+	return Channel{
+		Name:      domain.ChannelEmail,
+		Recipient: "email address",
+		Load: func(cfg *config.Config) (Sender, error) {
+			if cfg.Email.SmtpHost == "" {
+				return nil, nil
+			}
+			return NewEmailNotifier(EmailConfig{
+				SmtpHost:    cfg.Email.SmtpHost,
+				SmtpPort:    cfg.Email.SmtpPort,
+				User:        cfg.Email.User,
+				Pass:        cfg.Email.Pass,
+				TLSMode:     cfg.Email.TLSMode,
+				AuthMethod:  cfg.Email.AuthMethod,
+				PoolSize:    cfg.Email.PoolSize,
+				IdleTimeout: cfg.Email.IdleTimeout,
+				Timeout:     cfg.Email.Timeout,
+			}), nil
+		},
+		ValidateRecipient: func(recipient string) error {
+			addr, err := mail.ParseAddress(recipient)
+			if err != nil {
+				return err
+			}
+			if addr.Address != recipient {
+				return fmt.Errorf("expected a bare address, got %q", recipient)
+			}
+			return nil
+		},
+		ValidateOptions: func(raw json.RawMessage) error {
+			_, err := parseEmailOptions(raw)
+			return err
+		},
+	}
 }
 
 func (e *EmailNotifier) Send(ctx context.Context, notification *domain.Notification) error {
-	auth := smtp.PlainAuth("", e.cfg.User, e.cfg.Pass, e.cfg.SmtpHost)
-	to := []string{notification.UserID}
-	msg := []byte(fmt.Sprintf("From: %s\r\n"+
-		"To: %s\r\n"+
-		"Subject: Notification\r\n"+
-		"\r\n"+
-		"%s\r\n", e.cfg.User, notification.UserID, notification.Message))
-	addr := fmt.Sprintf("%s:%d", e.cfg.SmtpHost, e.cfg.SmtpPort)
+	to := []string{notification.Address}
+	opts, err := parseEmailOptions(notification.ChannelOptions)
+	if err != nil {
+		return domain.PermanentError(fmt.Errorf("%w: %v", domain.ErrInvalidOptions, err))
+	}
+	msg, err := (&emailMessage{
+		From:        e.cfg.User,
+		To:          notification.Address,
+		Subject:     notification.Subject,
+		Text:        notification.Message,
+		HTML:        notification.HTMLMessage,
+		Attachments: opts.Attachments,
+		Date:        time.Now(),
+	}).build()
+	if err != nil {
+		return domain.PermanentError(fmt.Errorf("failed to build email: %w", err))
+	}
 	zlog.Logger.Info().
-		Str("to", notification.UserID).
+		Str("to", notification.Address).
 		Str("channel", "email").
 		Str("id", notification.ID).
 		Msg("Sending email notification")
-	return smtp.SendMail(addr, auth, e.cfg.User, to, msg)
+	err = e.pool.send(ctx, e.cfg.User, to, msg)
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		err = &domain.ProviderError{Code: fmt.Sprintf("smtp %d", smtpErr.Code), Err: err}
+		// 5xx replies to the mail transaction such as 550 "mailbox does not
+		// exist" are final, 4xx replies ask the client to try again later.
+		// A rejected login or STARTTLS is fixed on our side and retried.
+		if smtpErr.Code >= 500 && !errors.Is(err, errSMTPSession) {
+			return domain.PermanentError(err)
+		}
+	}
+	return err
+}
+
+func (e *EmailNotifier) Close() error {
+	return e.pool.Close()
 }
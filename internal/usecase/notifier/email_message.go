@@ -0,0 +1,218 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultEmailSubject   = "Notification"
+	maxEmailAttachmentsSz = 10 << 20
+)
+
+// EmailOptions is the "email" part of the channel options.
+type EmailOptions struct {
+	Attachments []EmailAttachment `json:"attachments,omitempty"`
+}
+
+// EmailAttachment content is base64 in JSON.
+type EmailAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	Content     []byte `json:"content"`
+}
+
+func parseEmailOptions(raw json.RawMessage) (*EmailOptions, error) {
+	var opts EmailOptions
+	if isEmptyOptions(raw) {
+		return &opts, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&opts); err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, a := range opts.Attachments {
+		if a.Filename == "" {
+			return nil, errors.New("attachment filename is required")
+		}
+		if len(a.Content) == 0 {
+			return nil, fmt.Errorf("attachment %q is empty", a.Filename)
+		}
+		if a.ContentType != "" {
+			if _, _, err := mime.ParseMediaType(a.ContentType); err != nil {
+				return nil, fmt.Errorf("attachment %q: %w", a.Filename, err)
+			}
+		}
+		total += len(a.Content)
+	}
+	if total > maxEmailAttachmentsSz {
+		return nil, fmt.Errorf("attachments exceed %d bytes", maxEmailAttachmentsSz)
+	}
+	return &opts, nil
+}
+
+type emailMessage struct {
+	From        string
+	To          string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []EmailAttachment
+	Date        time.Time
+}
+
+// build renders the message as RFC 5322 with CRLF line endings. Header values
+// are RFC 2047 encoded and bodies are quoted-printable, so non-ASCII text
+// survives 7-bit relays. Dot-stuffing is left to the SMTP DATA writer.
+func (m *emailMessage) build() ([]byte, error) {
+	var buf bytes.Buffer
+	subject := m.Subject
+	if subject == "" {
+		subject = defaultEmailSubject
+	}
+	writeHeader(&buf, "From", m.From)
+	writeHeader(&buf, "To", m.To)
+	writeHeader(&buf, "Subject", mime.QEncoding.Encode("utf-8", subject))
+	writeHeader(&buf, "Date", m.Date.Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", fmt.Sprintf("<%s@%s>", uuid.New().String(), messageIDDomain(m.From)))
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	header, content, err := bodyPart(m.Text, m.HTML)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Attachments) == 0 {
+		for _, key := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+			if v := header.Get(key); v != "" {
+				writeHeader(&buf, key, v)
+			}
+		}
+		buf.WriteString("\r\n")
+		buf.Write(content)
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(&buf)
+	writeHeader(&buf, "Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mixed.Boundary()}))
+	buf.WriteString("\r\n")
+	part, err := mixed.CreatePart(header)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(content)
+	for _, a := range m.Attachments {
+		if err := writeAttachment(mixed, a); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bodyPart returns the headers and content of the message body: plain text
+// alone or multipart/alternative with the HTML version last, as clients
+// prefer the last alternative they can display.
+func bodyPart(text, html string) (textproto.MIMEHeader, []byte, error) {
+	var buf bytes.Buffer
+	if html == "" {
+		if err := writeQuotedPrintable(&buf, text); err != nil {
+			return nil, nil, err
+		}
+		return textproto.MIMEHeader{
+			"Content-Type":              {"text/plain; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		}, buf.Bytes(), nil
+	}
+	alt := multipart.NewWriter(&buf)
+	for _, p := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", html},
+	} {
+		w, err := alt.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := writeQuotedPrintable(w, p.body); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := alt.Close(); err != nil {
+		return nil, nil, err
+	}
+	return textproto.MIMEHeader{
+		"Content-Type": {mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": alt.Boundary()})},
+	}, buf.Bytes(), nil
+}
+
+func writeAttachment(mw *multipart.Writer, a EmailAttachment) error {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(a.Filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+	})
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(a.Content)
+	for len(encoded) > 76 {
+		io.WriteString(w, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	_, err = io.WriteString(w, encoded+"\r\n")
+	return err
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\n", "\r\n")
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, s); err != nil {
+		return err
+	}
+	if err := qp.Close(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\r\n")
+	return err
+}
+
+func writeHeader(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	buf.WriteString(": ")
+	buf.WriteString(value)
+	buf.WriteString("\r\n")
+}
+
+func messageIDDomain(from string) string {
+	if _, domain, ok := strings.Cut(from, "@"); ok && domain != "" {
+		return strings.TrimSuffix(domain, ">")
+	}
+	return "localhost"
+}
@@ -0,0 +1,371 @@
+package notifier
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+// smtpTestServer is a minimal in-process SMTP server. replies overrides the
+// reply to a command, e.g. "RCPT" or "AUTH"; messages holds the DATA of
+// accepted transactions.
+type smtpTestServer struct {
+	ln      net.Listener
+	replies map[string]string
+
+	mu       sync.Mutex
+	messages [][]byte
+}
+
+func newSMTPTestServer(t *testing.T, replies map[string]string) *smtpTestServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpTestServer{ln: ln, replies: replies}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *smtpTestServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpTestServer) received() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.messages
+}
+
+func (s *smtpTestServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpTestServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+	replyTo := func(cmd, line string) {
+		if override, ok := s.replies[cmd]; ok {
+			line = override
+		}
+		reply(line)
+	}
+	reply("220 test ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 test")
+		case strings.HasPrefix(cmd, "MAIL"), cmd == "RSET", cmd == "NOOP":
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT"):
+			replyTo("RCPT", "250 OK")
+		case strings.HasPrefix(cmd, "AUTH"):
+			replyTo("AUTH", "235 authenticated")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data bytes.Buffer
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.Bytes())
+			s.mu.Unlock()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func testEmailNotifier(port int) *EmailNotifier {
+	return NewEmailNotifier(EmailConfig{
+		SmtpHost:    "127.0.0.1",
+		SmtpPort:    port,
+		TLSMode:     SMTPTLSNone,
+		PoolSize:    1,
+		IdleTimeout: time.Minute,
+		Timeout:     2 * time.Second,
+	})
+}
+
+func testEmailNotification(t *testing.T) *domain.Notification {
+	t.Helper()
+	options, err := json.Marshal(EmailOptions{Attachments: []EmailAttachment{
+		{Filename: "report.csv", Content: []byte("id,total\n1,42\n")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &domain.Notification{
+		ID:             "6f1c2a9e-3b7d-4c55-9a51-0f2f4b8d1e77",
+		Channel:        domain.ChannelEmail,
+		Address:        "user@example.com",
+		Subject:        "Отчет готов",
+		Message:        "Привет!",
+		HTMLMessage:    "<p>Привет!</p>",
+		ChannelOptions: options,
+	}
+}
+
+func TestEmailSendOverSMTP(t *testing.T) {
+	srv := newSMTPTestServer(t, nil)
+	e := testEmailNotifier(srv.port())
+	defer e.Close()
+
+	if err := e.Send(context.Background(), testEmailNotification(t)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	messages := srv.received()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(messages[0]))
+	if err != nil {
+		t.Fatalf("message does not parse: %v", err)
+	}
+	if got := msg.Header.Get("To"); got != "user@example.com" {
+		t.Fatalf("To = %q", got)
+	}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil || subject != "Отчет готов" {
+		t.Fatalf("Subject = %q, %v", subject, err)
+	}
+	parts := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0].contentType, "multipart/alternative") {
+		t.Fatalf("top-level parts = %+v", parts)
+	}
+	if parts[1].filename != "report.csv" || parts[1].body != "id,total\n1,42\n" {
+		t.Fatalf("attachment = %+v", parts[1])
+	}
+}
+
+func TestEmailSendClassifiesSMTPReplies(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		kind  domain.DeliveryErrorKind
+		code  string
+	}{
+		{name: "mailbox unavailable is permanent", reply: "550 5.1.1 mailbox does not exist", kind: domain.DeliveryPermanent, code: "smtp 550"},
+		{name: "greylisting is transient", reply: "451 4.7.1 try again later", kind: domain.DeliveryTransient, code: "smtp 451"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newSMTPTestServer(t, map[string]string{"RCPT": tt.reply})
+			e := testEmailNotifier(srv.port())
+			defer e.Close()
+
+			err := e.Send(context.Background(), testEmailNotification(t))
+			if err == nil {
+				t.Fatal("Send() error = nil")
+			}
+			if got := domain.DeliveryErrorKindOf(err); got != tt.kind {
+				t.Fatalf("kind = %v, want %v (err = %v)", got, tt.kind, err)
+			}
+			if got := domain.ProviderCode(err); got != tt.code {
+				t.Fatalf("provider code = %q, want %q", got, tt.code)
+			}
+		})
+	}
+}
+
+func TestEmailSendRetriesRejectedLogin(t *testing.T) {
+	srv := newSMTPTestServer(t, map[string]string{"AUTH": "535 5.7.8 authentication credentials invalid"})
+	e := NewEmailNotifier(EmailConfig{
+		SmtpHost:    "127.0.0.1",
+		SmtpPort:    srv.port(),
+		User:        "noreply@example.com",
+		Pass:        "rotated",
+		TLSMode:     SMTPTLSNone,
+		AuthMethod:  SMTPAuthPlain,
+		PoolSize:    1,
+		IdleTimeout: time.Minute,
+		Timeout:     2 * time.Second,
+	})
+	defer e.Close()
+
+	err := e.Send(context.Background(), testEmailNotification(t))
+	if err == nil {
+		t.Fatal("Send() error = nil")
+	}
+	if got := domain.DeliveryErrorKindOf(err); got != domain.DeliveryTransient {
+		t.Fatalf("kind = %v, want transient (err = %v)", got, err)
+	}
+	if got := domain.ProviderCode(err); got != "smtp 535" {
+		t.Fatalf("provider code = %q, want %q", got, "smtp 535")
+	}
+}
+
+func TestEmailMessagePlainText(t *testing.T) {
+	raw, err := (&emailMessage{
+		From: "noreply@example.com",
+		To:   "user@example.com",
+		Text: strings.Repeat("длинная строка ", 20),
+		Date: time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC),
+	}).build()
+	if err != nil {
+		t.Fatalf("build() error = %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(raw), "\r\n"), "\r\n") {
+		if len(line) > 78 {
+			t.Fatalf("line longer than 78 characters: %q", line)
+		}
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("message does not parse: %v", err)
+	}
+	if got := msg.Header.Get("Subject"); got != defaultEmailSubject {
+		t.Fatalf("Subject = %q, want default", got)
+	}
+	if got := msg.Header.Get("Message-ID"); !strings.HasSuffix(got, "@example.com>") {
+		t.Fatalf("Message-ID = %q", got)
+	}
+	parts := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	if len(parts) != 1 || parts[0].body != strings.Repeat("длинная строка ", 20)+"\r\n" {
+		t.Fatalf("body = %+v", parts)
+	}
+}
+
+func TestEmailMessageAlternatives(t *testing.T) {
+	raw, err := (&emailMessage{
+		From: "noreply@example.com",
+		To:   "user@example.com",
+		Text: "plain",
+		HTML: "<b>html</b>",
+		Date: time.Now(),
+	}).build()
+	if err != nil {
+		t.Fatalf("build() error = %v", err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("message does not parse: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", mediaType, err)
+	}
+	parts := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0].contentType, "text/plain") || !strings.HasPrefix(parts[1].contentType, "text/html") {
+		t.Fatalf("alternatives = %+v (boundary %q)", parts, params["boundary"])
+	}
+	if parts[1].body != "<b>html</b>\r\n" {
+		t.Fatalf("html body = %q", parts[1].body)
+	}
+}
+
+func TestParseEmailOptionsLimits(t *testing.T) {
+	big := make([]byte, maxEmailAttachmentsSz+1)
+	tests := map[string]EmailOptions{
+		"missing filename": {Attachments: []EmailAttachment{{Content: []byte("x")}}},
+		"empty content":    {Attachments: []EmailAttachment{{Filename: "a.txt"}}},
+		"bad content type": {Attachments: []EmailAttachment{{Filename: "a.txt", ContentType: "text/", Content: []byte("x")}}},
+		"too large":        {Attachments: []EmailAttachment{{Filename: "a.bin", Content: big}}},
+	}
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			raw, err := json.Marshal(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := parseEmailOptions(raw); err == nil {
+				t.Fatal("parseEmailOptions() accepted invalid options")
+			}
+		})
+	}
+}
+
+type mimePart struct {
+	contentType string
+	filename    string
+	body        string
+}
+
+// readParts returns the top-level parts of a multipart body, or the body
+// itself when it is not multipart, with transfer encodings decoded.
+func readParts(t *testing.T, contentType string, body io.Reader) []mimePart {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("invalid Content-Type %q: %v", contentType, err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		data, err := io.ReadAll(quotedPrintableReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []mimePart{{contentType: contentType, body: string(data)}}
+	}
+	var parts []mimePart
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("invalid multipart body: %v", err)
+		}
+		// multipart.Part decodes quoted-printable itself; base64 is left to
+		// the caller.
+		var r io.Reader = p
+		if p.Header.Get("Content-Transfer-Encoding") == "base64" {
+			r = base64Reader(p)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, mimePart{
+			contentType: p.Header.Get("Content-Type"),
+			filename:    p.FileName(),
+			body:        string(data),
+		})
+	}
+}
+
+func quotedPrintableReader(r io.Reader) io.Reader {
+	return quotedprintable.NewReader(r)
+}
+
+func base64Reader(r io.Reader) io.Reader {
+	return base64.NewDecoder(base64.StdEncoding, r)
+}
@@ -0,0 +1,145 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"delayed-notifier/internal/config"
+	"delayed-notifier/internal/domain"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+type Sender interface {
+	Send(ctx context.Context, notification *domain.Notification) error
+}
+
+// Channel describes a delivery channel. Load returns a nil Sender when the
+// channel is not configured, in which case it stays disabled. A channel
+// without ValidateOptions accepts no channel options.
+type Channel struct {
+	Name              domain.NotificationChannel
+	Recipient         string
+	Load              func(cfg *config.Config) (Sender, error)
+	ValidateRecipient func(recipient string) error
+	ValidateOptions   func(raw json.RawMessage) error
+}
+
+type enabledChannel struct {
+	Channel
+	sender Sender
+}
+
+type Registry struct {
+	channels map[domain.NotificationChannel]*enabledChannel
+	order    []domain.NotificationChannel
+}
+
+func Builtin() []Channel {
+	return []Channel{
+		emailChannel(),
+		telegramChannel(),
+		webhookChannel(),
+	}
+}
+
+func NewRegistry(cfg *config.Config, channels ...Channel) (*Registry, error) {
+	r := &Registry{
+		channels: make(map[domain.NotificationChannel]*enabledChannel),
+	}
+	for _, ch := range channels {
+		if _, ok := r.channels[ch.Name]; ok {
+			return nil, fmt.Errorf("channel %q registered twice", ch.Name)
+		}
+		sender, err := ch.Load(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load channel %q: %w", ch.Name, err)
+		}
+		if sender == nil {
+			zlog.Logger.Info().Str("channel", string(ch.Name)).Msg("Channel is not configured, disabling")
+			continue
+		}
+		r.channels[ch.Name] = &enabledChannel{Channel: ch, sender: sender}
+		r.order = append(r.order, ch.Name)
+	}
+	return r, nil
+}
+
+func (r *Registry) Send(ctx context.Context, notification *domain.Notification) error {
+	ch, ok := r.channels[notification.Channel]
+	if !ok {
+		return domain.PermanentError(fmt.Errorf("%w: %q", domain.ErrUnknownChannel, notification.Channel))
+	}
+	return ch.sender.Send(ctx, notification)
+}
+
+func (r *Registry) Supports(name domain.NotificationChannel) bool {
+	_, ok := r.channels[name]
+	return ok
+}
+
+func (r *Registry) ValidateRecipient(name domain.NotificationChannel, recipient string) error {
+	ch, ok := r.channels[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", domain.ErrUnknownChannel, name)
+	}
+	if ch.ValidateRecipient == nil {
+		return nil
+	}
+	if err := ch.ValidateRecipient(recipient); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidRecipient, err)
+	}
+	return nil
+}
+
+// ValidateOptions checks options keyed by channel. Every part is checked by
+// its own channel; a part for a disabled or unknown channel is rejected.
+func (r *Registry) ValidateOptions(raw json.RawMessage) error {
+	parts, err := domain.ParseChannelOptions(raw)
+	if err != nil {
+		return err
+	}
+	for name, part := range parts {
+		ch, ok := r.channels[name]
+		if !ok {
+			return fmt.Errorf("%w: options for %q", domain.ErrUnknownChannel, name)
+		}
+		if ch.ValidateOptions == nil {
+			if !isEmptyOptions(part) {
+				return fmt.Errorf("%w: channel %q has no options", domain.ErrInvalidOptions, name)
+			}
+			continue
+		}
+		if err := ch.ValidateOptions(part); err != nil {
+			return fmt.Errorf("%w: %s: %v", domain.ErrInvalidOptions, name, err)
+		}
+	}
+	return nil
+}
+
+func (r *Registry) Channels() []domain.ChannelInfo {
+	infos := make([]domain.ChannelInfo, 0, len(r.order))
+	for _, name := range r.order {
+		infos = append(infos, domain.ChannelInfo{
+			Name:      name,
+			Recipient: r.channels[name].Recipient,
+		})
+	}
+	return infos
+}
+
+// Close releases resources held by senders, such as pooled connections.
+func (r *Registry) Close() error {
+	var errs []error
+	for _, name := range r.order {
+		if closer, ok := r.channels[name].sender.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close channel %q: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
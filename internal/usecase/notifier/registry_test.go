@@ -0,0 +1,50 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"delayed-notifier/internal/config"
+	"delayed-notifier/internal/domain"
+)
+
+type stubSender struct{}
+
+func (stubSender) Send(context.Context, *domain.Notification) error { return nil }
+
+func TestRegistryValidateOptions(t *testing.T) {
+	load := func(*config.Config) (Sender, error) { return stubSender{}, nil }
+	r, err := NewRegistry(&config.Config{},
+		Channel{Name: domain.ChannelEmail, Load: load, ValidateOptions: func(raw json.RawMessage) error {
+			_, err := parseEmailOptions(raw)
+			return err
+		}},
+		Channel{Name: domain.ChannelWebhook, Load: load},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		options string
+		want    error
+	}{
+		{name: "empty", options: ``},
+		{name: "own part", options: `{"email":{"attachments":[{"filename":"a.txt","content":"eA=="}]}}`},
+		{name: "empty part for channel without options", options: `{"webhook":{}}`},
+		{name: "not keyed by channel", options: `[1]`, want: domain.ErrInvalidOptions},
+		{name: "invalid part", options: `{"email":{"unknown":true}}`, want: domain.ErrInvalidOptions},
+		{name: "options for channel without options", options: `{"webhook":{"x":1}}`, want: domain.ErrInvalidOptions},
+		{name: "disabled channel", options: `{"telegram":{}}`, want: domain.ErrUnknownChannel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := r.ValidateOptions(json.RawMessage(tt.options))
+			if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("ValidateOptions() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
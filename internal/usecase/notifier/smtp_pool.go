@@ -0,0 +1,255 @@
+package notifier
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	SMTPTLSNone     = "none"
+	SMTPTLSStartTLS = "starttls"
+	SMTPTLSImplicit = "tls"
+
+	SMTPAuthPlain   = "plain"
+	SMTPAuthLogin   = "login"
+	SMTPAuthCRAMMD5 = "cram-md5"
+)
+
+// errSMTPSession marks failures to set up a session: the greeting, STARTTLS
+// and AUTH. Their replies are about the server or the credentials rather than
+// the message, so they never fail a notification for good.
+var errSMTPSession = errors.New("SMTP session setup failed")
+
+type smtpConn struct {
+	conn     net.Conn
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+// smtpPool keeps up to cfg.PoolSize authenticated connections open between
+// sends. Connections are handed out exclusively, so one transaction runs on
+// a connection at a time.
+type smtpPool struct {
+	cfg EmailConfig
+
+	mu     sync.Mutex
+	idle   []*smtpConn
+	closed bool
+}
+
+func newSMTPPool(cfg EmailConfig) *smtpPool {
+	return &smtpPool{cfg: cfg}
+}
+
+// send runs a single mail transaction. Every network operation is bounded by
+// ctx and cfg.Timeout; a cancelled ctx aborts the connection in flight.
+func (p *smtpPool) send(ctx context.Context, from string, to []string, msg []byte) error {
+	c, err := p.get(ctx)
+	if err != nil {
+		return err
+	}
+	stop := p.watch(ctx, c)
+	err = transaction(c.client, from, to, msg)
+	aborted := !stop()
+	if err == nil {
+		p.put(c)
+		return nil
+	}
+	// An SMTP reply leaves the session usable once the transaction is reset;
+	// anything else means the connection is in an unknown state.
+	var smtpErr *textproto.Error
+	if !aborted && errors.As(err, &smtpErr) && c.client.Reset() == nil {
+		p.put(c)
+	} else {
+		c.client.Close()
+	}
+	if aborted && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func transaction(c *smtp.Client, from string, to []string, msg []byte) error {
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// watch sets the I/O deadline for c and interrupts it when ctx is done. The
+// returned stop reports false if the connection was interrupted.
+func (p *smtpPool) watch(ctx context.Context, c *smtpConn) func() bool {
+	c.conn.SetDeadline(p.deadline(ctx))
+	return context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Unix(1, 0))
+	})
+}
+
+func (p *smtpPool) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(p.cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return deadline
+}
+
+func (p *smtpPool) get(ctx context.Context) (*smtpConn, error) {
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			return p.dial(ctx)
+		}
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if time.Since(c.lastUsed) > p.cfg.IdleTimeout {
+			c.client.Close()
+			continue
+		}
+		// Servers drop idle sessions on their own schedule.
+		stop := p.watch(ctx, c)
+		err := c.client.Noop()
+		if stop() && err == nil {
+			return c, nil
+		}
+		c.client.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (p *smtpPool) put(c *smtpConn) {
+	c.conn.SetDeadline(time.Time{})
+	c.lastUsed = time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || len(p.idle) >= p.cfg.PoolSize {
+		c.conn.SetDeadline(time.Now().Add(p.cfg.Timeout))
+		go c.client.Quit()
+		return
+	}
+	p.idle = append(p.idle, c)
+}
+
+func (p *smtpPool) dial(ctx context.Context) (*smtpConn, error) {
+	addr := net.JoinHostPort(p.cfg.SmtpHost, strconv.Itoa(p.cfg.SmtpPort))
+	dialer := &net.Dialer{Timeout: p.cfg.Timeout}
+	tlsConfig := &tls.Config{ServerName: p.cfg.SmtpHost}
+
+	var conn net.Conn
+	var err error
+	if p.cfg.TLSMode == SMTPTLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	c := &smtpConn{conn: conn}
+	stop := p.watch(ctx, c)
+	defer stop()
+
+	c.client, err = smtp.NewClient(conn, p.cfg.SmtpHost)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%w: failed to start SMTP session: %w", errSMTPSession, err)
+	}
+	if p.cfg.TLSMode == SMTPTLSStartTLS {
+		if ok, _ := c.client.Extension("STARTTLS"); !ok {
+			c.client.Close()
+			return nil, errors.New("SMTP server does not support STARTTLS")
+		}
+		if err := c.client.StartTLS(tlsConfig); err != nil {
+			c.client.Close()
+			return nil, fmt.Errorf("%w: failed to start TLS: %w", errSMTPSession, err)
+		}
+	}
+	if p.cfg.User != "" {
+		if err := c.client.Auth(p.auth()); err != nil {
+			c.client.Close()
+			return nil, fmt.Errorf("%w: failed to authenticate: %w", errSMTPSession, err)
+		}
+	}
+	return c, nil
+}
+
+func (p *smtpPool) auth() smtp.Auth {
+	switch p.cfg.AuthMethod {
+	case SMTPAuthLogin:
+		return &loginAuth{username: p.cfg.User, password: p.cfg.Pass}
+	case SMTPAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(p.cfg.User, p.cfg.Pass)
+	default:
+		return smtp.PlainAuth("", p.cfg.User, p.cfg.Pass, p.cfg.SmtpHost)
+	}
+}
+
+func (p *smtpPool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+	for _, c := range idle {
+		c.conn.SetDeadline(time.Now().Add(p.cfg.Timeout))
+		c.client.Quit()
+	}
+	return nil
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp does not provide.
+// Like smtp.PlainAuth it refuses to send credentials over plain text to
+// anything but localhost.
+type loginAuth struct {
+	username, password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(fromServer) {
+	case "Username:", "User Name\x00":
+		return []byte(a.username), nil
+	case "Password:", "Password\x00":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+	}
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -2,43 +2,193 @@ package notifier
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"strconv"
+	"time"
 
+	"delayed-notifier/internal/config"
 	"delayed-notifier/internal/domain"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	wbfredis "github.com/wb-go/wbf/redis"
 	"github.com/wb-go/wbf/zlog"
 )
 
+// TelegramNotifier shares one BotAPI client between all workers; the client
+// keeps no per-request state and is safe for concurrent Send calls.
 type TelegramNotifier struct {
-	cfg TelegramConfig
+	cfg     TelegramConfig
+	bot     *tgbotapi.BotAPI
+	limiter rateLimiter
 }
 
+// rateLimiter paces sends to the Bot API. Pause holds back further sends to
+// the chat after a 429 response.
+type rateLimiter interface {
+	Wait(ctx context.Context, chatID int64) error
+	Pause(ctx context.Context, chatID int64, d time.Duration)
+}
+
+const (
+	TelegramLimiterRedis = "redis"
+	TelegramLimiterLocal = "local"
+)
+
 type TelegramConfig struct {
 	BotToken string
+	// APIEndpoint is a format string in the form of tgbotapi.APIEndpoint.
+	// Empty means the public Bot API.
+	APIEndpoint string
+	// GlobalRate and ChatRate are messages per second for the whole bot and
+	// for a single chat.
+	GlobalRate float64
+	ChatRate   float64
+	// A 429 asking to wait no longer than MaxRetryAfterWait is waited out in
+	// place; longer waits are left to the broker.
+	MaxRetryAfterWait time.Duration
+	// Limiter selects where the rate limits are kept: TelegramLimiterRedis
+	// shares them between processes, TelegramLimiterLocal keeps them in
+	// memory and is only valid with a single sending process.
+	Limiter string
+	Redis   RedisConfig
 }
 
-func NewTelegramNotifier(cfg TelegramConfig) *TelegramNotifier {
-	return &TelegramNotifier{cfg: cfg}
+type RedisConfig struct {
+	Addr string
+	Pass string
+	DB   int
 }
 
-func (t *TelegramNotifier) Send(ctx context.Context, notification *domain.Notification) error {
-	bot, err := tgbotapi.NewBotAPI(t.cfg.BotToken)
+// NewTelegramNotifier checks the token with a getMe request, so a bad token
+// is reported at startup instead of on the first delivery.
+func NewTelegramNotifier(cfg TelegramConfig) (*TelegramNotifier, error) {
+	endpoint := cfg.APIEndpoint
+	if endpoint == "" {
+		endpoint = tgbotapi.APIEndpoint
+	}
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint(cfg.BotToken, endpoint)
 	if err != nil {
-		zlog.Logger.Error().Err(err).Str("id", notification.ID).Msg("Failed to create Telegram bot")
-		return err
+		return nil, fmt.Errorf("failed to create Telegram bot: %w", err)
+	}
+	zlog.Logger.Info().Str("bot", bot.Self.UserName).Msg("Telegram bot authorized")
+	var limiter rateLimiter
+	switch cfg.Limiter {
+	case TelegramLimiterLocal:
+		zlog.Logger.Warn().Msg("Telegram rate limits are kept in memory, run a single sending process")
+		limiter = newTelegramLimiter(cfg.GlobalRate, cfg.ChatRate)
+	default:
+		client := wbfredis.New(cfg.Redis.Addr, cfg.Redis.Pass, cfg.Redis.DB)
+		if err := client.Client.Ping(context.Background()).Err(); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to connect to Redis for Telegram rate limits: %w", err)
+		}
+		limiter = newRedisTelegramLimiter(client, cfg.GlobalRate, cfg.ChatRate)
+	}
+	return &TelegramNotifier{
+		cfg:     cfg,
+		bot:     bot,
+		limiter: limiter,
+	}, nil
+}
+
+func (t *TelegramNotifier) Close() error {
+	if closer, ok := t.limiter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func telegramChannel() Channel {
+	return Channel{
+		Name:      domain.ChannelTelegram,
+		Recipient: "numeric chat ID",
+		Load: func(cfg *config.Config) (Sender, error) {
+			if cfg.Telegram.BotToken == "" {
+				return nil, nil
+			}
+			return NewTelegramNotifier(TelegramConfig{
+				BotToken:          cfg.Telegram.BotToken,
+				APIEndpoint:       cfg.Telegram.APIEndpoint,
+				GlobalRate:        cfg.Telegram.GlobalRate,
+				ChatRate:          cfg.Telegram.ChatRate,
+				MaxRetryAfterWait: cfg.Telegram.MaxRetryAfterWait,
+				Limiter:           cfg.Telegram.Limiter,
+				Redis: RedisConfig{
+					Addr: cfg.RedisAddr(),
+					Pass: cfg.Redis.Pass,
+					DB:   cfg.Redis.DB,
+				},
+			})
+		},
+		ValidateRecipient: func(recipient string) error {
+			_, err := strconv.ParseInt(recipient, 10, 64)
+			return err
+		},
+		ValidateOptions: func(raw json.RawMessage) error {
+			_, err := parseTelegramOptions(raw)
+			return err
+		},
 	}
-	chatID, err := strconv.ParseInt(notification.UserID, 10, 64)
+}
+
+func (t *TelegramNotifier) Send(ctx context.Context, notification *domain.Notification) error {
+	chatID, err := strconv.ParseInt(notification.Address, 10, 64)
 	if err != nil {
 		zlog.Logger.Error().Err(err).Str("id", notification.ID).Msg("Invalid chat ID")
-		return err
+		return domain.PermanentError(err)
 	}
-	msg := tgbotapi.NewMessage(chatID, notification.Message)
+	opts, err := parseTelegramOptions(notification.ChannelOptions)
+	if err != nil {
+		return domain.PermanentError(fmt.Errorf("%w: %v", domain.ErrInvalidOptions, err))
+	}
+	msg := opts.buildMessage(chatID, notification.Message)
 	zlog.Logger.Info().
 		Int64("chat_id", chatID).
 		Str("channel", "telegram").
 		Str("id", notification.ID).
 		Msg("Sending Telegram notification")
-	_, err = bot.Send(msg)
-	return err
+	// A short retry_after is waited out once in place; anything else is
+	// returned so the usecase reschedules without counting an attempt.
+	for resent := false; ; resent = true {
+		if err := t.limiter.Wait(ctx, chatID); err != nil {
+			return err
+		}
+		_, err = t.bot.Send(msg)
+		err = classifyTelegramError(err)
+		retryAfter, limited := domain.RetryAfter(err)
+		if !limited {
+			return err
+		}
+		t.limiter.Pause(ctx, chatID, retryAfter)
+		if resent || retryAfter > t.cfg.MaxRetryAfterWait {
+			return err
+		}
+		zlog.Logger.Warn().
+			Int64("chat_id", chatID).
+			Dur("retry_after", retryAfter).
+			Str("id", notification.ID).
+			Msg("Telegram rate limit hit, waiting before resending")
+	}
+}
+
+// classifyTelegramError maps Bot API errors: 429 carries retry_after, other
+// 4xx codes (chat not found, bot blocked, bad token) will not succeed on retry.
+func classifyTelegramError(err error) error {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) {
+		return err
+	}
+	err = &domain.ProviderError{Code: fmt.Sprintf("telegram %d", tgErr.Code), Err: err}
+	switch {
+	case tgErr.Code == http.StatusTooManyRequests:
+		return domain.RateLimitedError(err, time.Duration(tgErr.RetryAfter)*time.Second)
+	case tgErr.Code >= 400 && tgErr.Code < 500:
+		return domain.PermanentError(err)
+	default:
+		return err
+	}
 }
@@ -0,0 +1,120 @@
+package notifier
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Idle per-chat buckets are dropped after this long; a full bucket behaves
+// the same as a fresh one.
+const chatLimiterIdleTTL = time.Minute
+
+var telegramLimiterStats = expvar.NewMap("telegram_limiter")
+
+type chatLimiter struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+	// pausedUntil is set from retry_after when Telegram answers 429.
+	pausedUntil time.Time
+}
+
+// telegramLimiter enforces the Bot API limits in memory: a global token
+// bucket shared by the workers of this process and one bucket per chat. It is
+// only correct when a single process sends to Telegram; see
+// redisTelegramLimiter otherwise.
+type telegramLimiter struct {
+	global    *rate.Limiter
+	chatRate  rate.Limit
+	chatBurst int
+
+	mu        sync.Mutex
+	chats     map[int64]*chatLimiter
+	lastSweep time.Time
+	tracked   expvar.Int
+}
+
+func newTelegramLimiter(globalRate, chatRate float64) *telegramLimiter {
+	l := &telegramLimiter{
+		global:    rate.NewLimiter(rate.Limit(globalRate), max(1, int(globalRate))),
+		chatRate:  rate.Limit(chatRate),
+		chatBurst: 1,
+		chats:     make(map[int64]*chatLimiter),
+		lastSweep: time.Now(),
+	}
+	telegramLimiterStats.Set("tracked_chats", &l.tracked)
+	return l
+}
+
+func (l *telegramLimiter) Wait(ctx context.Context, chatID int64) error {
+	started := time.Now()
+	chat, pausedFor := l.chat(chatID)
+	if pausedFor > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pausedFor):
+		}
+	}
+	if err := chat.Wait(ctx); err != nil {
+		return err
+	}
+	if err := l.global.Wait(ctx); err != nil {
+		return err
+	}
+	recordTelegramWait(time.Since(started))
+	return nil
+}
+
+func recordTelegramWait(waited time.Duration) {
+	telegramLimiterStats.Add("sends", 1)
+	if waited >= time.Millisecond {
+		telegramLimiterStats.Add("throttled", 1)
+		telegramLimiterStats.Add("wait_ms", waited.Milliseconds())
+	}
+}
+
+func (l *telegramLimiter) Pause(_ context.Context, chatID int64, d time.Duration) {
+	telegramLimiterStats.Add("rate_limited", 1)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c := l.getLocked(chatID)
+	if until := time.Now().Add(d); until.After(c.pausedUntil) {
+		c.pausedUntil = until
+	}
+}
+
+func (l *telegramLimiter) chat(chatID int64) (*rate.Limiter, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= chatLimiterIdleTTL {
+		l.sweepLocked(now)
+	}
+	c := l.getLocked(chatID)
+	c.lastUsed = now
+	return c.limiter, c.pausedUntil.Sub(now)
+}
+
+func (l *telegramLimiter) getLocked(chatID int64) *chatLimiter {
+	c, ok := l.chats[chatID]
+	if !ok {
+		c = &chatLimiter{limiter: rate.NewLimiter(l.chatRate, l.chatBurst)}
+		l.chats[chatID] = c
+		l.tracked.Set(int64(len(l.chats)))
+	}
+	return c
+}
+
+func (l *telegramLimiter) sweepLocked(now time.Time) {
+	for id, c := range l.chats {
+		if now.Sub(c.lastUsed) >= chatLimiterIdleTTL && now.After(c.pausedUntil) {
+			delete(l.chats, id)
+		}
+	}
+	l.lastSweep = now
+	l.tracked.Set(int64(len(l.chats)))
+}
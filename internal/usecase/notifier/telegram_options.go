@@ -0,0 +1,114 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// TelegramOptions is the "telegram" part of the channel options.
+// Photo and Document take either an http(s) URL or a Telegram file_id; with
+// an attachment the message text becomes its caption.
+type TelegramOptions struct {
+	ParseMode             string             `json:"parse_mode,omitempty"`
+	DisableNotification   bool               `json:"disable_notification,omitempty"`
+	DisableWebPagePreview bool               `json:"disable_web_page_preview,omitempty"`
+	Buttons               [][]TelegramButton `json:"buttons,omitempty"`
+	Photo                 string             `json:"photo,omitempty"`
+	Document              string             `json:"document,omitempty"`
+}
+
+type TelegramButton struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+func parseTelegramOptions(raw json.RawMessage) (*TelegramOptions, error) {
+	var opts TelegramOptions
+	if isEmptyOptions(raw) {
+		return &opts, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&opts); err != nil {
+		return nil, err
+	}
+	switch opts.ParseMode {
+	case "", tgbotapi.ModeMarkdownV2, tgbotapi.ModeHTML, tgbotapi.ModeMarkdown:
+	default:
+		return nil, fmt.Errorf("unsupported parse_mode %q", opts.ParseMode)
+	}
+	if opts.Photo != "" && opts.Document != "" {
+		return nil, errors.New("photo and document are mutually exclusive")
+	}
+	for _, row := range opts.Buttons {
+		if len(row) == 0 {
+			return nil, errors.New("button rows must not be empty")
+		}
+		for _, b := range row {
+			if b.Text == "" {
+				return nil, errors.New("button text is required")
+			}
+			u, err := url.Parse(b.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "tg") {
+				return nil, fmt.Errorf("button %q needs an http(s) or tg URL", b.Text)
+			}
+		}
+	}
+	return &opts, nil
+}
+
+// buildMessage maps the options onto the matching tgbotapi config.
+func (o *TelegramOptions) buildMessage(chatID int64, text string) tgbotapi.Chattable {
+	var markup any
+	if len(o.Buttons) > 0 {
+		rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(o.Buttons))
+		for _, row := range o.Buttons {
+			buttons := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
+			for _, b := range row {
+				buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonURL(b.Text, b.URL))
+			}
+			rows = append(rows, buttons)
+		}
+		markup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+	switch {
+	case o.Photo != "":
+		msg := tgbotapi.NewPhoto(chatID, telegramFile(o.Photo))
+		msg.Caption = text
+		msg.ParseMode = o.ParseMode
+		msg.DisableNotification = o.DisableNotification
+		msg.ReplyMarkup = markup
+		return msg
+	case o.Document != "":
+		msg := tgbotapi.NewDocument(chatID, telegramFile(o.Document))
+		msg.Caption = text
+		msg.ParseMode = o.ParseMode
+		msg.DisableNotification = o.DisableNotification
+		msg.ReplyMarkup = markup
+		return msg
+	default:
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ParseMode = o.ParseMode
+		msg.DisableNotification = o.DisableNotification
+		msg.DisableWebPagePreview = o.DisableWebPagePreview
+		msg.ReplyMarkup = markup
+		return msg
+	}
+}
+
+func telegramFile(ref string) tgbotapi.RequestFileData {
+	if u, err := url.Parse(ref); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return tgbotapi.FileURL(ref)
+	}
+	return tgbotapi.FileID(ref)
+}
+
+func isEmptyOptions(raw json.RawMessage) bool {
+	trimmed := bytes.TrimSpace(raw)
+	return len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) || bytes.Equal(trimmed, []byte("{}"))
+}
@@ -0,0 +1,124 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	wbfredis "github.com/wb-go/wbf/redis"
+	"github.com/wb-go/wbf/zlog"
+)
+
+const (
+	redisLimiterGlobalKey     = "telegram_limiter:global"
+	redisLimiterChatKeyPrefix = "telegram_limiter:chat:"
+)
+
+// reserveScript books a send slot with GCRA: the chat bucket first, then the
+// global bucket starting from the time the chat allows. Each bucket keeps its
+// theoretical arrival time "tat" in microseconds of the Redis clock, so all
+// processes share one schedule. Returns the wait in microseconds.
+var reserveScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local function reserve(key, at, interval, burst)
+	local tat = tonumber(redis.call('HGET', key, 'tat')) or 0
+	if tat < at then
+		tat = at
+	end
+	tat = tat + interval
+	redis.call('HSET', key, 'tat', tat)
+	local expire = tat
+	local paused = tonumber(redis.call('HGET', key, 'paused')) or 0
+	if paused > expire then
+		expire = paused
+	end
+	redis.call('PEXPIRE', key, math.ceil((expire - now) / 1000) + 1000)
+	local send = tat - burst * interval
+	if send < at then
+		send = at
+	end
+	return send
+end
+
+local at = now
+local paused = tonumber(redis.call('HGET', KEYS[1], 'paused')) or 0
+if paused > at then
+	at = paused
+end
+at = reserve(KEYS[1], at, tonumber(ARGV[1]), tonumber(ARGV[2]))
+at = reserve(KEYS[2], at, tonumber(ARGV[3]), tonumber(ARGV[4]))
+return at - now
+`)
+
+// pauseScript moves the chat's pause deadline forward by ARGV[1] microseconds.
+var pauseScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local deadline = now + tonumber(ARGV[1])
+local paused = tonumber(redis.call('HGET', KEYS[1], 'paused')) or 0
+if deadline > paused then
+	redis.call('HSET', KEYS[1], 'paused', deadline)
+	if redis.call('PTTL', KEYS[1]) < math.ceil(tonumber(ARGV[1]) / 1000) then
+		redis.call('PEXPIRE', KEYS[1], math.ceil(tonumber(ARGV[1]) / 1000) + 1000)
+	end
+end
+return 1
+`)
+
+// redisTelegramLimiter keeps the global and per-chat buckets in Redis, so the
+// Bot API limits hold across every process that sends to Telegram.
+type redisTelegramLimiter struct {
+	client         *wbfredis.Client
+	globalInterval int64
+	globalBurst    int
+	chatInterval   int64
+}
+
+func newRedisTelegramLimiter(client *wbfredis.Client, globalRate, chatRate float64) *redisTelegramLimiter {
+	return &redisTelegramLimiter{
+		client:         client,
+		globalInterval: int64(float64(time.Second/time.Microsecond) / globalRate),
+		globalBurst:    max(1, int(globalRate)),
+		chatInterval:   int64(float64(time.Second/time.Microsecond) / chatRate),
+	}
+}
+
+func (l *redisTelegramLimiter) Wait(ctx context.Context, chatID int64) error {
+	started := time.Now()
+	waitUs, err := reserveScript.Run(ctx, l.client.Client,
+		[]string{chatLimiterKey(chatID), redisLimiterGlobalKey},
+		l.chatInterval, 1, l.globalInterval, l.globalBurst,
+	).Int64()
+	if err != nil {
+		return fmt.Errorf("failed to reserve Telegram send slot: %w", err)
+	}
+	if waitUs > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(waitUs) * time.Microsecond):
+		}
+	}
+	recordTelegramWait(time.Since(started))
+	return nil
+}
+
+func (l *redisTelegramLimiter) Pause(ctx context.Context, chatID int64, d time.Duration) {
+	telegramLimiterStats.Add("rate_limited", 1)
+	err := pauseScript.Run(ctx, l.client.Client, []string{chatLimiterKey(chatID)}, d.Microseconds()).Err()
+	if err != nil {
+		zlog.Logger.Error().Err(err).Int64("chat_id", chatID).Msg("Failed to pause Telegram chat limiter")
+	}
+}
+
+func (l *redisTelegramLimiter) Close() error {
+	return l.client.Close()
+}
+
+func chatLimiterKey(chatID int64) string {
+	return redisLimiterChatKeyPrefix + strconv.FormatInt(chatID, 10)
+}
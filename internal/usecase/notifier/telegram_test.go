@@ -0,0 +1,44 @@
+package notifier
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"delayed-notifier/internal/domain"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestClassifyTelegramError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		kind domain.DeliveryErrorKind
+		wait time.Duration
+		code string
+	}{
+		{name: "network error is transient", err: errors.New("connection reset"), kind: domain.DeliveryTransient},
+		{name: "too many requests", err: &tgbotapi.Error{Code: 429, Message: "Too Many Requests", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 5}}, kind: domain.DeliveryRateLimited, wait: 5 * time.Second, code: "telegram 429"},
+		{name: "chat not found", err: &tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, kind: domain.DeliveryPermanent, code: "telegram 400"},
+		{name: "bot blocked", err: &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, kind: domain.DeliveryPermanent, code: "telegram 403"},
+		{name: "server error", err: &tgbotapi.Error{Code: 502, Message: "Bad Gateway"}, kind: domain.DeliveryTransient, code: "telegram 502"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyTelegramError(tt.err)
+			if got := domain.DeliveryErrorKindOf(err); got != tt.kind {
+				t.Fatalf("kind = %v, want %v", got, tt.kind)
+			}
+			if wait, _ := domain.RetryAfter(err); wait != tt.wait {
+				t.Fatalf("retry after = %v, want %v", wait, tt.wait)
+			}
+			if got := domain.ProviderCode(err); got != tt.code {
+				t.Fatalf("provider code = %q, want %q", got, tt.code)
+			}
+		})
+	}
+	if classifyTelegramError(nil) != nil {
+		t.Fatal("classifyTelegramError(nil) != nil")
+	}
+}
@@ -0,0 +1,222 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"delayed-notifier/internal/config"
+	"delayed-notifier/internal/domain"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+const (
+	webhookSignatureHeader = "X-Signature-256"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+)
+
+type WebhookNotifier struct {
+	cfg    WebhookConfig
+	client *http.Client
+}
+
+type WebhookConfig struct {
+	Secret  string
+	Timeout time.Duration
+	// AllowPrivateNetworks lets webhooks reach loopback, private and
+	// link-local addresses. Target URLs come from API clients, so this stays
+	// off outside of local setups.
+	AllowPrivateNetworks bool
+}
+
+var errForbiddenAddress = errors.New("webhook target address is not allowed")
+
+type webhookPayload struct {
+	ID         string         `json:"id"`
+	UserID     string         `json:"user_id"`
+	Channel    string         `json:"channel"`
+	Message    string         `json:"message"`
+	SendAt     time.Time      `json:"send_at"`
+	SeriesID   string         `json:"series_id,omitempty"`
+	ExternalID string         `json:"external_id,omitempty"`
+	Tags       []string       `json:"tags,omitempty"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+}
+
+func NewWebhookNotifier(cfg WebhookConfig) *WebhookNotifier {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateNetworks {
+		// The check runs on the resolved address of every connection, so it
+		// also covers redirects and DNS names pointing into the internal network.
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: controlPublicAddress}
+		transport.Proxy = nil
+		transport.DialContext = dialer.DialContext
+	}
+	return &WebhookNotifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout, Transport: transport},
+	}
+}
+
+func controlPublicAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errForbiddenAddress, address)
+	}
+	addr := addrPort.Addr().Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+		return fmt.Errorf("%w: %s", errForbiddenAddress, addr)
+	}
+	return nil
+}
+
+func webhookChannel() Channel {
+	return Channel{
+		Name:      domain.ChannelWebhook,
+		Recipient: "http(s) URL",
+		Load: func(cfg *config.Config) (Sender, error) {
+			// Unsigned webhooks can be forged by anyone who knows the URL.
+			if cfg.Webhook.Secret == "" {
+				return nil, nil
+			}
+			return NewWebhookNotifier(WebhookConfig{
+				Secret:               cfg.Webhook.Secret,
+				Timeout:              cfg.Webhook.Timeout,
+				AllowPrivateNetworks: cfg.Webhook.AllowPrivateNetworks,
+			}), nil
+		},
+		ValidateRecipient: func(recipient string) error {
+			u, err := url.Parse(recipient)
+			if err != nil {
+				return err
+			}
+			if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("expected an absolute http(s) URL, got %q", recipient)
+			}
+			return nil
+		},
+	}
+}
+
+// Send posts the notification to the URL in Address. The body is signed
+// as "<timestamp>.<body>" so receivers can reject replayed requests.
+func (w *WebhookNotifier) Send(ctx context.Context, notification *domain.Notification) error {
+	body, err := json.Marshal(webhookPayload{
+		ID:         notification.ID,
+		UserID:     notification.UserID,
+		Channel:    string(notification.Channel),
+		Message:    notification.Message,
+		SendAt:     notification.SendAt,
+		SeriesID:   notification.SeriesID,
+		ExternalID: notification.ExternalID,
+		Tags:       notification.Tags,
+		Metadata:   notification.Metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, notification.Address, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+w.sign(timestamp, body))
+	zlog.Logger.Info().
+		Str("url", notification.Address).
+		Str("channel", "webhook").
+		Str("id", notification.ID).
+		Msg("Sending webhook notification")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("failed to send webhook: %w", err)
+		if errors.Is(err, errForbiddenAddress) {
+			return domain.PermanentError(err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return classifyWebhookResponse(resp, time.Now())
+}
+
+// permanentWebhookStatuses are the responses that will not change on a retry
+// of the same request: it is malformed, unauthorized or has nowhere to go.
+// Other 4xx such as 408, 409 and 425 are transient by definition.
+var permanentWebhookStatuses = map[int]bool{
+	http.StatusBadRequest:            true,
+	http.StatusUnauthorized:          true,
+	http.StatusForbidden:             true,
+	http.StatusNotFound:              true,
+	http.StatusMethodNotAllowed:      true,
+	http.StatusGone:                  true,
+	http.StatusRequestEntityTooLarge: true,
+	http.StatusUnsupportedMediaType:  true,
+	http.StatusUnprocessableEntity:   true,
+}
+
+// classifyWebhookResponse maps the status code: 429 with Retry-After is
+// throttling, permanentWebhookStatuses end delivery over the channel, any
+// other non-2xx response is retried.
+func classifyWebhookResponse(resp *http.Response, now time.Time) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err := &domain.ProviderError{
+		Code: fmt.Sprintf("http %d", resp.StatusCode),
+		Err:  fmt.Errorf("webhook responded with status %d", resp.StatusCode),
+	}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+			return domain.RateLimitedError(err, retryAfter)
+		}
+		return err
+	case permanentWebhookStatuses[resp.StatusCode]:
+		return domain.PermanentError(err)
+	default:
+		return err
+	}
+}
+
+// parseRetryAfter accepts both forms of the header: delay in seconds and an
+// HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(at.Sub(now), 0), true
+}
+
+func (w *WebhookNotifier) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.cfg.Secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
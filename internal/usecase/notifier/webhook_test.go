@@ -0,0 +1,140 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+func testWebhookNotification(url string) *domain.Notification {
+	return &domain.Notification{
+		ID:      "6f1c2a9e-3b7d-4c55-9a51-0f2f4b8d1e77",
+		UserID:  "user-1",
+		Channel: domain.ChannelWebhook,
+		Address: url,
+		Message: "hello",
+		SendAt:  time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC),
+		Tags:    []string{"orders"},
+	}
+}
+
+func TestWebhookSendSignsBody(t *testing.T) {
+	const secret = "s3cret"
+	var (
+		timestamp, signature string
+		body                 []byte
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timestamp = r.Header.Get(webhookTimestampHeader)
+		signature = r.Header.Get(webhookSignatureHeader)
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w := NewWebhookNotifier(WebhookConfig{Secret: secret, Timeout: time.Second, AllowPrivateNetworks: true})
+	if err := w.Send(context.Background(), testWebhookNotification(srv.URL)); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Fatalf("signature = %q, want %q", signature, want)
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	if payload.ID != "6f1c2a9e-3b7d-4c55-9a51-0f2f4b8d1e77" || payload.Message != "hello" || payload.Channel != "webhook" {
+		t.Fatalf("payload = %+v", payload)
+	}
+}
+
+func TestWebhookSendClassifiesStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		kind       domain.DeliveryErrorKind
+		wait       time.Duration
+	}{
+		{name: "server error is transient", status: http.StatusBadGateway, kind: domain.DeliveryTransient},
+		{name: "not found is permanent", status: http.StatusNotFound, kind: domain.DeliveryPermanent},
+		{name: "unauthorized is permanent", status: http.StatusUnauthorized, kind: domain.DeliveryPermanent},
+		{name: "gone is permanent", status: http.StatusGone, kind: domain.DeliveryPermanent},
+		{name: "request timeout is transient", status: http.StatusRequestTimeout, kind: domain.DeliveryTransient},
+		{name: "conflict is transient", status: http.StatusConflict, kind: domain.DeliveryTransient},
+		{name: "too early is transient", status: http.StatusTooEarly, kind: domain.DeliveryTransient},
+		{name: "unlisted client error is transient", status: http.StatusTeapot, kind: domain.DeliveryTransient},
+		{name: "429 with seconds", status: http.StatusTooManyRequests, retryAfter: "7", kind: domain.DeliveryRateLimited, wait: 7 * time.Second},
+		{name: "429 without Retry-After", status: http.StatusTooManyRequests, kind: domain.DeliveryTransient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			w := NewWebhookNotifier(WebhookConfig{Secret: "s", Timeout: time.Second, AllowPrivateNetworks: true})
+			err := w.Send(context.Background(), testWebhookNotification(srv.URL))
+			if err == nil {
+				t.Fatal("Send() error = nil")
+			}
+			if got := domain.DeliveryErrorKindOf(err); got != tt.kind {
+				t.Fatalf("kind = %v, want %v (err = %v)", got, tt.kind, err)
+			}
+			if wait, _ := domain.RetryAfter(err); wait != tt.wait {
+				t.Fatalf("retry after = %v, want %v", wait, tt.wait)
+			}
+			var pe *domain.ProviderError
+			if !errors.As(err, &pe) || !strings.HasPrefix(pe.Code, "http ") {
+				t.Fatalf("error %v does not carry the provider code", err)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfterDate(t *testing.T) {
+	now := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	got, ok := parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now)
+	if !ok || got != 90*time.Second {
+		t.Fatalf("parseRetryAfter() = %v, %v, want 90s", got, ok)
+	}
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Fatal("parseRetryAfter() accepted an invalid value")
+	}
+}
+
+func TestWebhookBlocksPrivateNetworks(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	w := NewWebhookNotifier(WebhookConfig{Secret: "s", Timeout: time.Second})
+	err := w.Send(context.Background(), testWebhookNotification(srv.URL))
+	if !errors.Is(err, errForbiddenAddress) || domain.DeliveryErrorKindOf(err) != domain.DeliveryPermanent {
+		t.Fatalf("Send() error = %v, want permanent errForbiddenAddress", err)
+	}
+	if called {
+		t.Fatal("request reached the loopback server")
+	}
+}
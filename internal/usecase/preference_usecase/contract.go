@@ -0,0 +1,13 @@
+package preference_usecase
+
+import (
+	"context"
+
+	"delayed-notifier/internal/domain"
+)
+
+type PreferenceRepository interface {
+	Save(ctx context.Context, pref *domain.Preferences) (*domain.Preferences, error)
+	Get(ctx context.Context, userID string) (*domain.Preferences, error)
+	Delete(ctx context.Context, userID string) error
+}
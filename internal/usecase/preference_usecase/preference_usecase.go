@@ -0,0 +1,100 @@
+package preference_usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+const minutesPerDay = 24 * 60
+
+type PreferenceUsecase struct {
+	repo PreferenceRepository
+}
+
+func NewPreferenceUsecase(repo PreferenceRepository) *PreferenceUsecase {
+	return &PreferenceUsecase{repo: repo}
+}
+
+func (u *PreferenceUsecase) SavePreferences(ctx context.Context, userID string, dto *domain.SavePreferences) (*domain.Preferences, error) {
+	if dto.Timezone == "" {
+		dto.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(dto.Timezone); err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", domain.ErrInvalidPreferences, dto.Timezone)
+	}
+	if q := dto.QuietHours; q != nil {
+		if q.Start < 0 || q.Start >= minutesPerDay || q.End < 0 || q.End >= minutesPerDay {
+			return nil, fmt.Errorf("%w: quiet hours out of range", domain.ErrInvalidPreferences)
+		}
+		if q.Start == q.End {
+			return nil, fmt.Errorf("%w: quiet hours must not be empty", domain.ErrInvalidPreferences)
+		}
+	}
+	now := time.Now()
+	return u.repo.Save(ctx, &domain.Preferences{
+		UserID:             userID,
+		Timezone:           dto.Timezone,
+		QuietHours:         dto.QuietHours,
+		OptedOutChannels:   dto.OptedOutChannels,
+		OptedOutCategories: dto.OptedOutCategories,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	})
+}
+
+func (u *PreferenceUsecase) GetPreferences(ctx context.Context, userID string) (*domain.Preferences, error) {
+	pref, err := u.repo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if pref == nil {
+		return nil, domain.ErrPreferencesNotFound
+	}
+	return pref, nil
+}
+
+func (u *PreferenceUsecase) DeletePreferences(ctx context.Context, userID string) error {
+	return u.repo.Delete(ctx, userID)
+}
+
+// CheckDelivery decides what to do with a notification that is about to be
+// sent at now. Users without preferences receive everything.
+func (u *PreferenceUsecase) CheckDelivery(ctx context.Context, notif *domain.Notification, now time.Time) (domain.DeliveryDecision, error) {
+	pref, err := u.repo.Get(ctx, notif.UserID)
+	if err != nil {
+		return domain.DeliveryDecision{}, err
+	}
+	if pref == nil {
+		return domain.DeliveryDecision{Action: domain.DeliverNow}, nil
+	}
+	if notif.Category != "" && slices.Contains(pref.OptedOutCategories, notif.Category) {
+		return domain.DeliveryDecision{
+			Action: domain.Suppress,
+			Reason: fmt.Sprintf("user opted out of category %q", notif.Category),
+		}, nil
+	}
+	if slices.Contains(pref.OptedOutChannels, notif.Channel) {
+		return domain.DeliveryDecision{
+			Action: domain.SkipChannel,
+			Reason: fmt.Sprintf("user opted out of channel %q", notif.Channel),
+		}, nil
+	}
+	if pref.QuietHours != nil {
+		loc, err := time.LoadLocation(pref.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		if until, ok := pref.QuietHours.EndAfter(now.In(loc)); ok {
+			return domain.DeliveryDecision{
+				Action: domain.DeliverLater,
+				Until:  until,
+				Reason: "quiet hours",
+			}, nil
+		}
+	}
+	return domain.DeliveryDecision{Action: domain.DeliverNow}, nil
+}
@@ -0,0 +1,104 @@
+package preference_usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+type memoryRepo struct {
+	prefs map[string]*domain.Preferences
+}
+
+func (r *memoryRepo) Save(_ context.Context, pref *domain.Preferences) (*domain.Preferences, error) {
+	r.prefs[pref.UserID] = pref
+	return pref, nil
+}
+
+func (r *memoryRepo) Get(_ context.Context, userID string) (*domain.Preferences, error) {
+	return r.prefs[userID], nil
+}
+
+func (r *memoryRepo) Delete(_ context.Context, userID string) error {
+	delete(r.prefs, userID)
+	return nil
+}
+
+func TestCheckDelivery(t *testing.T) {
+	repo := &memoryRepo{prefs: map[string]*domain.Preferences{}}
+	u := NewPreferenceUsecase(repo)
+	ctx := context.Background()
+	_, err := u.SavePreferences(ctx, "user-1", &domain.SavePreferences{
+		Timezone:           "Asia/Tokyo",
+		QuietHours:         &domain.QuietHours{Start: 22 * 60, End: 7 * 60},
+		OptedOutChannels:   []domain.NotificationChannel{domain.ChannelTelegram},
+		OptedOutCategories: []string{"marketing"},
+	})
+	if err != nil {
+		t.Fatalf("SavePreferences() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		notif  domain.Notification
+		now    string
+		action domain.DeliveryAction
+		until  string
+	}{
+		{name: "no preferences", notif: domain.Notification{UserID: "user-2", Channel: domain.ChannelEmail}, now: "2030-01-01T15:00:00Z", action: domain.DeliverNow},
+		{name: "daytime in Tokyo", notif: domain.Notification{UserID: "user-1", Channel: domain.ChannelEmail}, now: "2030-01-01T03:00:00Z", action: domain.DeliverNow},
+		// 15:00 UTC is midnight in Tokyo; quiet hours end at 07:00 local.
+		{name: "night in Tokyo", notif: domain.Notification{UserID: "user-1", Channel: domain.ChannelEmail}, now: "2030-01-01T15:00:00Z", action: domain.DeliverLater, until: "2030-01-01T22:00:00Z"},
+		{name: "opted out category wins over quiet hours", notif: domain.Notification{UserID: "user-1", Channel: domain.ChannelEmail, Category: "marketing"}, now: "2030-01-01T15:00:00Z", action: domain.Suppress},
+		{name: "opted out channel", notif: domain.Notification{UserID: "user-1", Channel: domain.ChannelTelegram}, now: "2030-01-01T03:00:00Z", action: domain.SkipChannel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now, err := time.Parse(time.RFC3339, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := u.CheckDelivery(ctx, &tt.notif, now)
+			if err != nil {
+				t.Fatalf("CheckDelivery() error = %v", err)
+			}
+			if got.Action != tt.action {
+				t.Fatalf("action = %q, want %q (%s)", got.Action, tt.action, got.Reason)
+			}
+			if tt.until != "" {
+				until, err := time.Parse(time.RFC3339, tt.until)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !got.Until.Equal(until) {
+					t.Fatalf("until = %v, want %v", got.Until.UTC(), until)
+				}
+			}
+		})
+	}
+}
+
+func TestSavePreferencesValidation(t *testing.T) {
+	u := NewPreferenceUsecase(&memoryRepo{prefs: map[string]*domain.Preferences{}})
+	tests := map[string]*domain.SavePreferences{
+		"unknown timezone":   {Timezone: "Mars/Olympus"},
+		"empty quiet hours":  {QuietHours: &domain.QuietHours{Start: 60, End: 60}},
+		"start out of range": {QuietHours: &domain.QuietHours{Start: -1, End: 60}},
+		"end out of range":   {QuietHours: &domain.QuietHours{Start: 0, End: 24 * 60}},
+	}
+	for name, dto := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := u.SavePreferences(context.Background(), "user-1", dto); !errors.Is(err, domain.ErrInvalidPreferences) {
+				t.Fatalf("SavePreferences() error = %v, want ErrInvalidPreferences", err)
+			}
+		})
+	}
+
+	pref, err := u.SavePreferences(context.Background(), "user-1", &domain.SavePreferences{})
+	if err != nil || pref.Timezone != "UTC" {
+		t.Fatalf("SavePreferences() = %+v, %v, want UTC by default", pref, err)
+	}
+}
@@ -0,0 +1,19 @@
+package recipient_usecase
+
+import (
+	"context"
+
+	"delayed-notifier/internal/domain"
+)
+
+type RecipientRepository interface {
+	Save(ctx context.Context, rcpt *domain.Recipient) (*domain.Recipient, error)
+	Get(ctx context.Context, userID string) (*domain.Recipient, error)
+	GetMany(ctx context.Context, userIDs []string) (map[string]*domain.Recipient, error)
+	Delete(ctx context.Context, userID string) error
+}
+
+type ChannelValidator interface {
+	Supports(name domain.NotificationChannel) bool
+	ValidateRecipient(name domain.NotificationChannel, recipient string) error
+}
@@ -0,0 +1,109 @@
+package recipient_usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+type RecipientUsecase struct {
+	repo     RecipientRepository
+	channels ChannelValidator
+}
+
+func NewRecipientUsecase(repo RecipientRepository, channels ChannelValidator) *RecipientUsecase {
+	return &RecipientUsecase{
+		repo:     repo,
+		channels: channels,
+	}
+}
+
+func (u *RecipientUsecase) SaveRecipient(ctx context.Context, userID string, dto *domain.SaveRecipient) (*domain.Recipient, error) {
+	for ch, addr := range dto.Addresses {
+		if err := u.channels.ValidateRecipient(ch, addr); err != nil {
+			return nil, err
+		}
+	}
+	for _, ch := range dto.PreferredChannels {
+		if _, ok := dto.Addresses[ch]; !ok {
+			return nil, fmt.Errorf("%w %q", domain.ErrNoAddress, ch)
+		}
+	}
+	now := time.Now()
+	return u.repo.Save(ctx, &domain.Recipient{
+		UserID:            userID,
+		Addresses:         dto.Addresses,
+		PreferredChannels: dto.PreferredChannels,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	})
+}
+
+func (u *RecipientUsecase) GetRecipient(ctx context.Context, userID string) (*domain.Recipient, error) {
+	rcpt, err := u.repo.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if rcpt == nil {
+		return nil, domain.ErrRecipientNotFound
+	}
+	return rcpt, nil
+}
+
+func (u *RecipientUsecase) DeleteRecipient(ctx context.Context, userID string) error {
+	return u.repo.Delete(ctx, userID)
+}
+
+// LoadRecipients reads the directory entries of the given users in one query,
+// so a batch of notifications is resolved without a lookup per item.
+func (u *RecipientUsecase) LoadRecipients(ctx context.Context, userIDs []string) (map[string]*domain.Recipient, error) {
+	return u.repo.GetMany(ctx, userIDs)
+}
+
+// ResolveChannel picks the channel for a new notification and returns the
+// address it will be delivered to. rcpt is the user's directory entry from
+// LoadRecipients; users missing from the directory (nil) keep the old
+// behavior: user_id is the address and the channel must be given.
+func (u *RecipientUsecase) ResolveChannel(rcpt *domain.Recipient, userID string, channel domain.NotificationChannel) (domain.NotificationChannel, string, error) {
+	if rcpt == nil {
+		if channel == "" {
+			return "", "", domain.ErrChannelRequired
+		}
+		return channel, userID, nil
+	}
+	if channel == "" {
+		for _, ch := range rcpt.PreferredChannels {
+			if u.channels.Supports(ch) {
+				channel = ch
+				break
+			}
+		}
+		if channel == "" {
+			return "", "", domain.ErrChannelRequired
+		}
+	}
+	addr, ok := rcpt.Addresses[channel]
+	if !ok {
+		return "", "", fmt.Errorf("%w %q", domain.ErrNoAddress, channel)
+	}
+	return channel, addr, nil
+}
+
+// ResolveAddress returns the address to deliver to at send time, so changes
+// in the directory apply to notifications that are already scheduled.
+func (u *RecipientUsecase) ResolveAddress(ctx context.Context, userID string, channel domain.NotificationChannel) (string, error) {
+	rcpt, err := u.repo.Get(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if rcpt == nil {
+		return userID, nil
+	}
+	addr, ok := rcpt.Addresses[channel]
+	if !ok {
+		return "", fmt.Errorf("%w %q", domain.ErrNoAddress, channel)
+	}
+	return addr, nil
+}
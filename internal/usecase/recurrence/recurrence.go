@@ -0,0 +1,100 @@
+package recurrence
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"delayed-notifier/internal/domain"
+
+	"github.com/robfig/cron/v3"
+	"github.com/teambition/rrule-go"
+)
+
+type schedule interface {
+	// next returns the first occurrence at or after t, or the zero time.
+	next(t time.Time) time.Time
+}
+
+type cronSchedule struct {
+	schedule cron.Schedule
+	loc      *time.Location
+}
+
+func (c cronSchedule) next(t time.Time) time.Time {
+	// cron.Schedule.Next is exclusive and rounds up to whole seconds.
+	return c.schedule.Next(t.In(c.loc).Add(-time.Nanosecond))
+}
+
+type rruleSchedule struct {
+	rule *rrule.RRule
+}
+
+func (r rruleSchedule) next(t time.Time) time.Time {
+	return r.rule.After(t, true)
+}
+
+func Validate(rec domain.Recurrence, startsAt time.Time) error {
+	_, err := parse(rec, startsAt)
+	return err
+}
+
+// Next returns the first occurrence of the series at or after the given time.
+// ok is false when the series has no occurrences left.
+func Next(rec domain.Recurrence, startsAt, after time.Time) (time.Time, bool, error) {
+	s, err := parse(rec, startsAt)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if after.Before(startsAt) {
+		after = startsAt
+	}
+	next := s.next(after)
+	if next.IsZero() {
+		return time.Time{}, false, nil
+	}
+	if rec.Until != nil && next.After(*rec.Until) {
+		return time.Time{}, false, nil
+	}
+	return next, true, nil
+}
+
+func parse(rec domain.Recurrence, startsAt time.Time) (schedule, error) {
+	loc, err := location(rec.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	switch rec.Kind {
+	case domain.RecurrenceCron:
+		s, err := cron.ParseStandard(rec.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidRecurrence, err)
+		}
+		return cronSchedule{schedule: s, loc: loc}, nil
+	case domain.RecurrenceRRule:
+		expr := strings.TrimPrefix(strings.TrimSpace(rec.Expression), "RRULE:")
+		opt, err := rrule.StrToROptionInLocation(expr, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidRecurrence, err)
+		}
+		opt.Dtstart = startsAt.In(loc)
+		rule, err := rrule.NewRRule(*opt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidRecurrence, err)
+		}
+		return rruleSchedule{rule: rule}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", domain.ErrInvalidRecurrence, rec.Kind)
+	}
+}
+
+func location(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", domain.ErrInvalidRecurrence, name)
+	}
+	return loc, nil
+}
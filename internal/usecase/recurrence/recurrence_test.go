@@ -0,0 +1,129 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"delayed-notifier/internal/domain"
+)
+
+func mustTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		rec      domain.Recurrence
+		startsAt string
+		after    string
+		want     string // empty when the series is over
+	}{
+		{
+			name:     "cron in timezone",
+			rec:      domain.Recurrence{Kind: domain.RecurrenceCron, Expression: "0 9 * * *", Timezone: "Europe/Moscow"},
+			startsAt: "2030-01-01T00:00:00Z",
+			after:    "2030-01-01T00:00:00Z",
+			want:     "2030-01-01T06:00:00Z",
+		},
+		{
+			name:     "cron is inclusive",
+			rec:      domain.Recurrence{Kind: domain.RecurrenceCron, Expression: "0 9 * * *", Timezone: "Europe/Moscow"},
+			startsAt: "2030-01-01T00:00:00Z",
+			after:    "2030-01-01T06:00:00Z",
+			want:     "2030-01-01T06:00:00Z",
+		},
+		{
+			name:     "cron moves to the next day",
+			rec:      domain.Recurrence{Kind: domain.RecurrenceCron, Expression: "0 9 * * *", Timezone: "Europe/Moscow"},
+			startsAt: "2030-01-01T00:00:00Z",
+			after:    "2030-01-01T06:00:01Z",
+			want:     "2030-01-02T06:00:00Z",
+		},
+		{
+			name:     "cron across daylight saving change",
+			rec:      domain.Recurrence{Kind: domain.RecurrenceCron, Expression: "30 9 * * *", Timezone: "America/New_York"},
+			startsAt: "2030-03-09T00:00:00Z",
+			after:    "2030-03-09T15:00:00Z",
+			want:     "2030-03-10T13:30:00Z",
+		},
+		{
+			name:     "starts at startsAt when after is earlier",
+			rec:      domain.Recurrence{Kind: domain.RecurrenceCron, Expression: "0 * * * *"},
+			startsAt: "2030-01-01T10:30:00Z",
+			after:    "2029-12-31T00:00:00Z",
+			want:     "2030-01-01T11:00:00Z",
+		},
+		{
+			name:     "rrule weekly",
+			rec:      domain.Recurrence{Kind: domain.RecurrenceRRule, Expression: "FREQ=WEEKLY;BYDAY=MO;BYHOUR=10;BYMINUTE=0;BYSECOND=0"},
+			startsAt: "2030-01-01T00:00:00Z",
+			after:    "2030-01-01T00:00:00Z",
+			want:     "2030-01-07T10:00:00Z",
+		},
+		{
+			name:     "rrule with prefix and timezone",
+			rec:      domain.Recurrence{Kind: domain.RecurrenceRRule, Expression: "RRULE:FREQ=DAILY;BYHOUR=8;BYMINUTE=0;BYSECOND=0", Timezone: "Asia/Tokyo"},
+			startsAt: "2030-01-01T00:00:00Z",
+			after:    "2030-01-01T00:00:00Z",
+			want:     "2030-01-01T23:00:00Z",
+		},
+		{
+			name:     "rrule count exhausted",
+			rec:      domain.Recurrence{Kind: domain.RecurrenceRRule, Expression: "FREQ=DAILY;COUNT=2"},
+			startsAt: "2030-01-01T10:00:00Z",
+			after:    "2030-01-02T10:00:01Z",
+		},
+		{
+			name: "until reached",
+			rec: domain.Recurrence{
+				Kind:       domain.RecurrenceCron,
+				Expression: "0 9 * * *",
+				Until:      func() *time.Time { u := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC); return &u }(),
+			},
+			startsAt: "2030-01-01T00:00:00Z",
+			after:    "2030-01-01T09:00:01Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := Next(tt.rec, mustTime(t, tt.startsAt), mustTime(t, tt.after))
+			if err != nil {
+				t.Fatalf("Next() error = %v", err)
+			}
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("Next() = %v, want no occurrence", got)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("Next() reported no occurrence, want %s", tt.want)
+			}
+			if want := mustTime(t, tt.want); !got.Equal(want) {
+				t.Fatalf("Next() = %v, want %v", got.UTC(), want)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsInvalidRecurrence(t *testing.T) {
+	startsAt := mustTime(t, "2030-01-01T00:00:00Z")
+	tests := []domain.Recurrence{
+		{Kind: domain.RecurrenceCron, Expression: "every day"},
+		{Kind: domain.RecurrenceRRule, Expression: "FREQ=SOMETIMES"},
+		{Kind: domain.RecurrenceCron, Expression: "0 9 * * *", Timezone: "Mars/Olympus"},
+		{Kind: "yearly", Expression: "0 9 * * *"},
+	}
+	for _, rec := range tests {
+		if err := Validate(rec, startsAt); !errors.Is(err, domain.ErrInvalidRecurrence) {
+			t.Errorf("Validate(%+v) error = %v, want ErrInvalidRecurrence", rec, err)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package template_usecase
+
+import (
+	"context"
+
+	"delayed-notifier/internal/domain"
+)
+
+type TemplateRepository interface {
+	Create(ctx context.Context, tmpl *domain.Template) error
+	Get(ctx context.Context, id string) (*domain.Template, error)
+	List(ctx context.Context) ([]*domain.Template, error)
+	Update(ctx context.Context, tmpl *domain.Template) (*domain.Template, error)
+	Delete(ctx context.Context, id string) error
+}
@@ -0,0 +1,163 @@
+package template_usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+
+	"delayed-notifier/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type TemplateUsecase struct {
+	repo TemplateRepository
+}
+
+func NewTemplateUsecase(repo TemplateRepository) *TemplateUsecase {
+	return &TemplateUsecase{repo: repo}
+}
+
+func (u *TemplateUsecase) CreateTemplate(ctx context.Context, dto *domain.SaveTemplate) (*domain.Template, error) {
+	if err := validateBodies(dto); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &domain.Template{
+		ID:            uuid.New().String(),
+		Name:          dto.Name,
+		TextBody:      dto.TextBody,
+		HTMLBody:      dto.HTMLBody,
+		ChannelBodies: dto.ChannelBodies,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := u.repo.Create(ctx, tmpl); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func (u *TemplateUsecase) GetTemplate(ctx context.Context, id string) (*domain.Template, error) {
+	tmpl, err := u.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return nil, domain.ErrTemplateNotFound
+	}
+	return tmpl, nil
+}
+
+func (u *TemplateUsecase) ListTemplates(ctx context.Context) ([]*domain.Template, error) {
+	return u.repo.List(ctx)
+}
+
+func (u *TemplateUsecase) UpdateTemplate(ctx context.Context, id string, dto *domain.SaveTemplate) (*domain.Template, error) {
+	if err := validateBodies(dto); err != nil {
+		return nil, err
+	}
+	return u.repo.Update(ctx, &domain.Template{
+		ID:            id,
+		Name:          dto.Name,
+		TextBody:      dto.TextBody,
+		HTMLBody:      dto.HTMLBody,
+		ChannelBodies: dto.ChannelBodies,
+		UpdatedAt:     time.Now(),
+	})
+}
+
+func (u *TemplateUsecase) DeleteTemplate(ctx context.Context, id string) error {
+	return u.repo.Delete(ctx, id)
+}
+
+// Render executes the template for the given channel with params. Missing
+// params are reported as domain.ErrTemplateParams, so the same call validates
+// a create request.
+func (u *TemplateUsecase) Render(
+	ctx context.Context,
+	id string,
+	channel domain.NotificationChannel,
+	params map[string]any,
+) (*domain.RenderedMessage, error) {
+	tmpl, err := u.GetTemplate(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return u.RenderTemplate(tmpl, channel, params)
+}
+
+// RenderTemplate is Render for a template the caller has already loaded, so a
+// batch can render many notifications from one read.
+func (u *TemplateUsecase) RenderTemplate(
+	tmpl *domain.Template,
+	channel domain.NotificationChannel,
+	params map[string]any,
+) (*domain.RenderedMessage, error) {
+	name, textBody := "text", tmpl.TextBody
+	if body, ok := tmpl.ChannelBodies[channel]; ok {
+		name, textBody = string(channel), body
+	}
+	text, err := parseText(name, textBody)
+	if err != nil {
+		return nil, err
+	}
+	html, err := parseHTML(tmpl.HTMLBody)
+	if err != nil {
+		return nil, err
+	}
+	if params == nil {
+		params = map[string]any{}
+	}
+	var rendered domain.RenderedMessage
+	var buf bytes.Buffer
+	if err := text.Execute(&buf, params); err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrTemplateParams, err)
+	}
+	rendered.Text = buf.String()
+	if html != nil {
+		buf.Reset()
+		if err := html.Execute(&buf, params); err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrTemplateParams, err)
+		}
+		rendered.HTML = buf.String()
+	}
+	return &rendered, nil
+}
+
+func validateBodies(dto *domain.SaveTemplate) error {
+	if _, err := parseText("text", dto.TextBody); err != nil {
+		return err
+	}
+	if _, err := parseHTML(dto.HTMLBody); err != nil {
+		return err
+	}
+	for channel, body := range dto.ChannelBodies {
+		if _, err := parseText(string(channel), body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseText(name, body string) (*texttemplate.Template, error) {
+	text, err := texttemplate.New(name).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s body: %v", domain.ErrInvalidTemplate, name, err)
+	}
+	return text, nil
+}
+
+func parseHTML(body string) (*htmltemplate.Template, error) {
+	if body == "" {
+		return nil, nil
+	}
+	html, err := htmltemplate.New("html").Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: html body: %v", domain.ErrInvalidTemplate, err)
+	}
+	return html, nil
+}
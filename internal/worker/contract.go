@@ -0,0 +1,15 @@
+package worker
+
+import (
+	"context"
+
+	wbfrabbit "github.com/wb-go/wbf/rabbitmq"
+)
+
+type NotificationProcessor interface {
+	ProcessNotification(ctx context.Context, id string, version int) error
+}
+
+type MessageConsumer interface {
+	Consume(ctx context.Context, queue string, handler wbfrabbit.MessageHandler) error
+}
@@ -1 +1,52 @@
 package worker
+
+import (
+	"context"
+	"encoding/json"
+
+	"delayed-notifier/internal/domain"
+
+	"github.com/rabbitmq/amqp091-go"
+	"github.com/wb-go/wbf/zlog"
+)
+
+const notificationsQueue = "notifications"
+
+type Worker struct {
+	consumer  MessageConsumer
+	processor NotificationProcessor
+}
+
+func NewWorker(consumer MessageConsumer, processor NotificationProcessor) *Worker {
+	return &Worker{
+		consumer:  consumer,
+		processor: processor,
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) error {
+	zlog.Logger.Info().Str("queue", notificationsQueue).Msg("Starting delivery worker")
+	return w.consumer.Consume(ctx, notificationsQueue, w.handle)
+}
+
+func (w *Worker) handle(ctx context.Context, msg amqp091.Delivery) error {
+	var payload struct {
+		ID      string `json:"id"`
+		Version int    `json:"version"`
+	}
+	if err := json.Unmarshal(msg.Body, &payload); err != nil {
+		zlog.Logger.Error().Err(err).Msg("Failed to unmarshal message")
+		return err
+	}
+	if payload.ID == "" {
+		zlog.Logger.Error().Msg("Missing ID in payload")
+		return domain.ErrNotFound
+	}
+	err := w.processor.ProcessNotification(ctx, payload.ID, payload.Version)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("id", payload.ID).Msg("Failed to process notification")
+		return err
+	}
+	zlog.Logger.Info().Str("id", payload.ID).Msg("Notification processed successfully")
+	return nil
+}
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255);
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS request_hash VARCHAR(64);
+ALTER TABLE notifications ADD CONSTRAINT notifications_idempotency_key_key UNIQUE (idempotency_key);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_idempotency_key_key;
+ALTER TABLE notifications DROP COLUMN IF EXISTS request_hash;
+ALTER TABLE notifications DROP COLUMN IF EXISTS idempotency_key;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_notifications_created ON notifications (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_status_created ON notifications (status, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications (user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_channel_created ON notifications (channel, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_notifications_send_at ON notifications (send_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_notifications_send_at;
+DROP INDEX IF EXISTS idx_notifications_channel_created;
+DROP INDEX IF EXISTS idx_notifications_user_created;
+DROP INDEX IF EXISTS idx_notifications_status_created;
+DROP INDEX IF EXISTS idx_notifications_created;
+-- +goose StatementEnd
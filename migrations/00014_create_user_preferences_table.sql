@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id VARCHAR(100) PRIMARY KEY,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    quiet_start SMALLINT,
+    quiet_end SMALLINT,
+    opted_out_channels TEXT[] NOT NULL DEFAULT '{}',
+    opted_out_categories TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS category VARCHAR(100);
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS suppression_reason TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE notifications DROP COLUMN IF EXISTS suppression_reason;
+ALTER TABLE notifications DROP COLUMN IF EXISTS category;
+DROP TABLE IF EXISTS user_preferences;
+-- +goose StatementEnd
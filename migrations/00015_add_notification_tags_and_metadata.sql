@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS client_id VARCHAR(100);
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS external_id VARCHAR(255);
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS metadata JSONB;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_external_id
+    ON notifications (COALESCE(client_id, ''), external_id)
+    WHERE external_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_notifications_tags ON notifications USING GIN (tags);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_notifications_tags;
+DROP INDEX IF EXISTS idx_notifications_external_id;
+ALTER TABLE notifications DROP COLUMN IF EXISTS metadata;
+ALTER TABLE notifications DROP COLUMN IF EXISTS tags;
+ALTER TABLE notifications DROP COLUMN IF EXISTS external_id;
+ALTER TABLE notifications DROP COLUMN IF EXISTS client_id;
+-- +goose StatementEnd
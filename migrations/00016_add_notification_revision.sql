@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS revision BIGINT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_notification_revision() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.revision := OLD.revision + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notifications_bump_revision
+    BEFORE UPDATE ON notifications
+    FOR EACH ROW EXECUTE FUNCTION bump_notification_revision();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS notifications_bump_revision ON notifications;
+DROP FUNCTION IF EXISTS bump_notification_revision();
+ALTER TABLE notifications DROP COLUMN IF EXISTS revision;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Published entries are deleted by the relay from now on.
+DELETE FROM outbox WHERE processed_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 1;
+-- +goose StatementEnd
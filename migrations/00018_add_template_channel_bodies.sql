@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE templates ADD COLUMN IF NOT EXISTS channel_bodies JSONB NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE templates DROP COLUMN IF EXISTS channel_bodies;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Before this migration one options payload was checked against every channel
+-- of the chain, so each channel gets a copy of it.
+UPDATE notifications n
+SET channel_options = (
+    SELECT jsonb_object_agg(c, n.channel_options)
+    FROM unnest(COALESCE(NULLIF(n.channels, '{}'), ARRAY[n.channel])) AS c
+)
+WHERE jsonb_typeof(channel_options) = 'object' AND channel_options <> '{}'::jsonb;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE notifications
+SET channel_options = channel_options -> channel
+WHERE jsonb_typeof(channel_options) = 'object' AND channel_options <> '{}'::jsonb;
+-- +goose StatementEnd
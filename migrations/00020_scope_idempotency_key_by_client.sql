@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE notifications DROP CONSTRAINT IF EXISTS notifications_idempotency_key_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_idempotency_key
+    ON notifications (COALESCE(client_id, ''), idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_notifications_idempotency_key;
+ALTER TABLE notifications ADD CONSTRAINT notifications_idempotency_key_key UNIQUE (idempotency_key);
+-- +goose StatementEnd
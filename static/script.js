@@ -2,11 +2,14 @@ class NotificationManager {
     constructor() {
         this.baseUrl = '/api/v1';
         this.currentFilter = 'all';
+        this.notifications = [];
+        this.nextCursor = null;
         this.init();
     }
 
     init() {
         this.bindEvents();
+        this.loadChannels();
         this.loadNotifications();
         this.setMinDateTime();
     }
@@ -23,6 +26,11 @@ class NotificationManager {
             this.loadNotifications();
         });
 
+        // Следующая страница
+        document.getElementById('loadMoreBtn').addEventListener('click', () => {
+            this.loadNotifications(true);
+        });
+
         // Фильтр статусов
         document.getElementById('statusFilter').addEventListener('change', (e) => {
             this.currentFilter = e.target.value;
@@ -47,6 +55,24 @@ class NotificationManager {
         document.getElementById('send_at').min = now.toISOString().slice(0, 16);
     }
 
+    async loadChannels() {
+        try {
+            const response = await fetch(`${this.baseUrl}/channels`);
+            if (!response.ok) throw new Error('Ошибка загрузки каналов');
+
+            const channels = await response.json();
+            const select = document.getElementById('channel');
+            channels.forEach(channel => {
+                const option = document.createElement('option');
+                option.value = channel.name;
+                option.textContent = this.getChannelDisplayName(channel.name);
+                select.appendChild(option);
+            });
+        } catch (error) {
+            this.showMessage('Не удалось загрузить список каналов', 'error');
+        }
+    }
+
     async createNotification() {
         const form = document.getElementById('createForm');
         const formData = new FormData(form);
@@ -82,16 +108,28 @@ class NotificationManager {
         }
     }
 
-    async loadNotifications() {
+    async loadNotifications(append = false) {
         this.showLoading(true);
         this.hideError();
 
         try {
-            const response = await fetch(`${this.baseUrl}/notifications`);
+            const params = new URLSearchParams();
+            if (this.currentFilter !== 'all') {
+                params.set('status', this.currentFilter);
+            }
+            if (append && this.nextCursor) {
+                params.set('cursor', this.nextCursor);
+            }
+            const response = await fetch(`${this.baseUrl}/notifications?${params}`);
             if (!response.ok) throw new Error('Ошибка загрузки уведомлений');
             
-            const notifications = await response.json();
-            this.displayNotifications(notifications);
+            const page = await response.json();
+            this.notifications = append
+                ? this.notifications.concat(page.notifications)
+                : page.notifications;
+            this.nextCursor = page.next_cursor || null;
+            document.getElementById('loadMoreBtn').classList.toggle('hidden', !this.nextCursor);
+            this.displayNotifications(this.notifications);
             
         } catch (error) {
             this.showError('Не удалось загрузить уведомления');
@@ -108,12 +146,7 @@ class NotificationManager {
             return;
         }
 
-        // Фильтрация по статусу
-        const filteredNotifications = this.currentFilter === 'all' 
-            ? notifications 
-            : notifications.filter(n => n.status === this.currentFilter);
-
-        container.innerHTML = filteredNotifications.map(notification => `
+        container.innerHTML = notifications.map(notification => `
             <div class="notification-card" data-id="${notification.id}">
                 <div class="notification-header">
                     <div>
@@ -205,6 +238,16 @@ class NotificationManager {
                 <div class="detail-label">Обновлено</div>
                 <div class="detail-value">${this.formatDateTime(notification.updated_at)}</div>
             </div>
+            ${(notification.attempts || []).map(attempt => `
+                <div class="detail-item">
+                    <div class="detail-label">Попытка ${this.formatDateTime(attempt.started_at)}</div>
+                    <div class="detail-value">
+                        ${attempt.success ? '✅ Успешно' : `⚠️ ${this.escapeHtml(attempt.error || '')}`}
+                        ${attempt.provider_code ? `(${this.escapeHtml(attempt.provider_code)})` : ''}
+                        · ${attempt.duration_ms} мс
+                    </div>
+                </div>
+            `).join('')}
         `;
         
         this.showModal();
@@ -237,7 +280,8 @@ class NotificationManager {
     getChannelDisplayName(channel) {
         const channels = {
             'email': '📧 Email',
-            'telegram': '📱 Telegram'
+            'telegram': '📱 Telegram',
+            'webhook': '🔗 Webhook'
         };
         return channels[channel] || channel;
     }
@@ -245,9 +289,11 @@ class NotificationManager {
     getStatusDisplayName(status) {
         const statuses = {
             'pending': '⏳ Ожидает',
+            'processing': '🔄 Отправляется',
             'sent': '✅ Отправлено',
             'cancelled': '❌ Отменено',
-            'failed': '⚠️ Ошибка'
+            'failed': '⚠️ Ошибка',
+            'suppressed': '🔕 Подавлено'
         };
         return statuses[status] || status;
     }